@@ -0,0 +1,88 @@
+package main
+
+import (
+    "sync"
+    "github.com/hajimehoshi/ebiten/audio"
+    "github.com/Insood/go-gmb/gmb"
+)
+
+// AUDIOSAMPLERATE - Samples per second sent to the speakers
+var AUDIOSAMPLERATE = 44100
+
+// AUDIOBUFFERFRAMES - How many frames of audio are queued ahead of the speakers.
+// More adds latency; fewer risks running dry and crackling
+var AUDIOBUFFERFRAMES = 4
+
+// MAXFRAMESPERTICK - Upper bound on how many frames are emulated per ebiten tick to catch up
+// with the audio, so that a stalled audio device can not freeze the window
+var MAXFRAMESPERTICK = 4
+
+// audioStream - The emulator writes samples in; the ebiten audio player reads them out
+// as 16-bit little endian stereo. The amount of queued audio paces the emulator
+type audioStream struct {
+    mutex sync.Mutex
+    buffer []byte
+    player *audio.Player
+}
+
+// Read - Called by the audio player from its own goroutine
+func (stream *audioStream) Read(p []byte) (int, error) {
+    stream.mutex.Lock()
+    defer stream.mutex.Unlock()
+
+    if len(stream.buffer) == 0 {
+        // The emulator fell behind. Play a little silence rather than stalling the player
+        n := len(p)
+        if n > 1024 {
+            n = 1024
+        }
+        for i := 0; i < n; i++ {
+            p[i] = 0
+        }
+        return n, nil
+    }
+    n := copy(p, stream.buffer)
+    stream.buffer = stream.buffer[n:]
+    return n, nil
+}
+
+// Close - The stream lives as long as the program
+func (stream *audioStream) Close() error {
+    return nil
+}
+
+// write - Queues interleaved left/right samples
+func (stream *audioStream) write(samples []int16) {
+    stream.mutex.Lock()
+    defer stream.mutex.Unlock()
+    for _, sample := range samples {
+        stream.buffer = append(stream.buffer, uint8(sample), uint8(uint16(sample) >> 8))
+    }
+}
+
+// needsFrames - True while less than AUDIOBUFFERFRAMES frames of audio are queued.
+// The speakers consume exactly 59.73 frames of audio per second, so emulating a frame
+// whenever this is true runs the Game Boy at its real speed
+func (stream *audioStream) needsFrames() bool {
+    stream.mutex.Lock()
+    defer stream.mutex.Unlock()
+    bytesPerFrame := float64(AUDIOSAMPLERATE * 4) * float64(gmb.CYCLESPERFRAME) / gmb.CLOCKSPEED
+    return float64(len(stream.buffer)) < bytesPerFrame * float64(AUDIOBUFFERFRAMES)
+}
+
+// startAudio - Opens the audio device and starts playing from an empty stream
+func startAudio() (*audioStream, error) {
+    context, err := audio.NewContext(AUDIOSAMPLERATE)
+    if err != nil {
+        return nil, err
+    }
+    stream := new(audioStream)
+    stream.player, err = audio.NewPlayer(context, stream)
+    if err != nil {
+        return nil, err
+    }
+    if err := stream.player.Play(); err != nil {
+        return nil, err
+    }
+    return stream, nil
+}
@@ -0,0 +1,417 @@
+package main
+
+import (
+    "bufio"
+    "fmt"
+    "os"
+    "strconv"
+    "strings"
+    "github.com/Insood/go-gmb/gmb"
+)
+
+// DEBUGGERHELP - Printed by the h command
+var DEBUGGERHELP = `Numbers are hexadecimal, with or without a 0x or $ prefix (except in expressions and the decimal IDs)
+  b [addr] [ignore <n>] [if <expr>]
+                    Set a breakpoint; without an address the condition is checked at every instruction.
+                    The first n matches are only counted
+  tp [addr] [ignore <n>] [if <expr>]
+                    Set a tracepoint: like b, but logs the registers and keeps running
+  d <id>            Delete a breakpoint         bl           List the breakpoints and their hit counts
+  p <expr>          Evaluate an expression
+  wp <addr>[-<end>] [r|w|rw] [==<value>] [changed]
+                    Stop when memory is read/written (default w), only for the value or for changes
+  wd <id>           Delete a watchpoint         wl           List the watchpoints
+  s [count]         Step one (or count) instructions
+  n                 Step over CALL/RST          out          Run until the current function returns
+  c                 Continue until a breakpoint or Ctrl+C
+  u <addr>          Run until PC reaches addr
+  r                 Show the registers          set <reg> <value>  Change A-L, F, AF-HL, SP or PC
+  x <addr> [len]    Hex dump memory             w <addr> <byte>... Write memory
+  l [addr]          Disassemble around PC (or from addr)
+  h                 This help                   q            Quit
+An empty line repeats the last command
+
+Expressions are written like C, e.g. pc == 0x2A10 && a == 5 && [0xFF44] > 140 && !zf
+Numbers are decimal unless they start with 0x or $, [addr] reads a byte of memory. Names: ` + gmb.EXPRESSIONVARIABLES
+
+// GDBADDRESS - Where go-gmb gdb listens by default. Only local clients can connect
+var GDBADDRESS = "localhost:2345"
+
+// debuggerSession - The state of the debug command line
+type debuggerSession struct {
+    gb *gmb.GameBoy
+    debugger *gmb.Debugger
+}
+
+// debuggerMain - go-gmb debug <romname>: an interactive command line debugger
+func debuggerMain(romName string) error {
+    gb, err := gmb.Load(romName, gmb.WithDisplay(false), gmb.WithSerialOutput(os.Stdout))
+    if err != nil {
+        return err
+    }
+    defer flushSave(gb)
+    session := debuggerSession{gb: gb, debugger: gmb.NewDebugger(gb)}
+    session.debugger.SetTraceOutput(os.Stdout)
+
+    // Ctrl+C stops a running program instead of quitting
+    interrupted := interruptChannel()
+    go func() {
+        for range interrupted {
+            session.debugger.Interrupt()
+        }
+    }()
+
+    fmt.Println("Type h for help")
+    session.showLocation()
+    input := bufio.NewScanner(os.Stdin)
+    lastCommand := ""
+    for {
+        fmt.Print("(gmb) ")
+        if !input.Scan() {
+            return input.Err()
+        }
+        line := strings.TrimSpace(input.Text())
+        if line == "" {
+            line = lastCommand
+        }
+        lastCommand = line
+        fields := strings.Fields(line)
+        if len(fields) == 0 {
+            continue
+        }
+        if fields[0] == "q" || fields[0] == "quit" {
+            return nil
+        }
+        session.debugger.ResetInterrupt() // Ctrl+C at the prompt should not stop the next command
+        if err := session.execute(fields[0], fields[1:]); err != nil {
+            fmt.Println(err)
+        }
+    }
+}
+
+// gdbMain - go-gmb gdb <romname> [address]: waits for GDB (target remote <address>) and lets it
+// drive the machine. The game only runs while GDB continues or steps it
+func gdbMain(romName string, address string) error {
+    gb, err := gmb.Load(romName, gmb.WithDisplay(false), gmb.WithSerialOutput(os.Stdout))
+    if err != nil {
+        return err
+    }
+    defer flushSave(gb)
+    server, err := gmb.ListenGDB(gb, address)
+    if err != nil {
+        return err
+    }
+    defer server.Close()
+    go func() {
+        <-interruptChannel()
+        server.Close()
+    }()
+
+    fmt.Printf("Waiting for GDB on %s (target remote %s)\n", server.Addr(), server.Addr())
+    return server.Serve()
+}
+
+// execute - Runs one debugger command
+func (session *debuggerSession) execute(command string, args []string) error {
+    debugger := session.debugger
+    switch command {
+    case "b", "break", "tp", "trace":
+        breakpoint, err := parseBreakpoint(args)
+        if err != nil {
+            return err
+        }
+        breakpoint.Trace = command == "tp" || command == "trace"
+        fmt.Printf("Breakpoint %d: %s\n", debugger.AddBreakpoint(breakpoint), breakpoint)
+    case "d", "delete":
+        if len(args) == 0 {
+            return fmt.Errorf("Missing breakpoint ID")
+        }
+        id, err := strconv.Atoi(args[0])
+        if err != nil || !debugger.RemoveBreakpoint(id) {
+            return fmt.Errorf("No breakpoint %s", args[0])
+        }
+    case "bl":
+        for _, breakpoint := range debugger.Breakpoints() {
+            fmt.Printf("%d: %s\n", breakpoint.ID, breakpoint)
+        }
+    case "p", "print":
+        value, err := debugger.Evaluate(strings.Join(args, " "))
+        if err != nil {
+            return err
+        }
+        fmt.Printf("%d (0x%X)\n", value, value)
+    case "wp", "watch":
+        watchpoint, err := parseWatchpoint(args)
+        if err != nil {
+            return err
+        }
+        fmt.Printf("Watchpoint %d: %s\n", debugger.AddWatchpoint(watchpoint), watchpoint)
+    case "wd":
+        if len(args) == 0 {
+            return fmt.Errorf("Missing watchpoint ID")
+        }
+        id, err := strconv.Atoi(args[0])
+        if err != nil || !debugger.RemoveWatchpoint(id) {
+            return fmt.Errorf("No watchpoint %s", args[0])
+        }
+    case "wl":
+        for _, watchpoint := range debugger.Watchpoints() {
+            fmt.Printf("%d: %s\n", watchpoint.ID, watchpoint)
+        }
+    case "s", "step":
+        count := uint64(1)
+        if len(args) > 0 {
+            parsed, err := parseNumber(args[0])
+            if err != nil {
+                return err
+            }
+            count = parsed
+        }
+        for i := uint64(0); i < count; i++ {
+            if reason, err := debugger.Step(); reason != gmb.StopStep {
+                session.stopped(reason, err)
+                return nil
+            }
+        }
+        session.showLocation()
+    case "n", "next":
+        session.stopped(debugger.StepOver())
+    case "out", "finish":
+        session.stopped(debugger.StepOut())
+    case "c", "continue":
+        session.stopped(debugger.Continue())
+    case "u", "until":
+        address, err := parseAddressArgument(args)
+        if err != nil {
+            return err
+        }
+        session.stopped(debugger.RunTo(address))
+    case "r", "regs":
+        fmt.Println(debugger.Registers())
+    case "set":
+        if len(args) != 2 {
+            return fmt.Errorf("Usage: set <register> <value>")
+        }
+        value, err := parseNumber(args[1])
+        if err != nil {
+            return err
+        }
+        return session.setRegister(strings.ToUpper(args[0]), uint16(value))
+    case "x":
+        address, err := parseAddressArgument(args)
+        if err != nil {
+            return err
+        }
+        length := 64
+        if len(args) > 1 {
+            parsed, err := parseNumber(args[1])
+            if err != nil {
+                return err
+            }
+            length = int(parsed)
+        }
+        fmt.Println(debugger.HexDump(address, length))
+    case "w":
+        address, err := parseAddressArgument(args)
+        if err != nil {
+            return err
+        }
+        var data []uint8
+        for _, arg := range args[1:] {
+            value, err := parseNumber(arg)
+            if err != nil || value > 0xFF {
+                return fmt.Errorf("Invalid byte %q", arg)
+            }
+            data = append(data, uint8(value))
+        }
+        debugger.WriteMemory(address, data)
+    case "l", "list":
+        var instructions []gmb.DisassembledInstruction
+        if len(args) > 0 {
+            address, err := parseAddressArgument(args)
+            if err != nil {
+                return err
+            }
+            instructions = debugger.Disassemble(address, 16)
+        } else {
+            instructions = debugger.DisassembleAround(debugger.Registers().PC, 6, 9)
+        }
+        session.printInstructions(instructions)
+    case "h", "help":
+        fmt.Println(DEBUGGERHELP)
+    default:
+        return fmt.Errorf("Unknown command %q, type h for help", command)
+    }
+    return nil
+}
+
+// stopped - Reports the result of a run command
+func (session *debuggerSession) stopped(reason gmb.StopReason, err error) {
+    switch reason {
+    case gmb.StopError:
+        fmt.Println(stopDiagnostic(session.gb, err))
+        return
+    case gmb.StopInterrupted:
+        fmt.Printf("Stopped: %s\n", reason)
+    case gmb.StopBreakpoint:
+        for _, breakpoint := range session.debugger.BreakpointHits() {
+            fmt.Printf("Breakpoint %d: %s\n", breakpoint.ID, breakpoint)
+        }
+    case gmb.StopWatchpoint:
+        for _, hit := range session.debugger.WatchpointHits() {
+            fmt.Println(hit)
+        }
+    }
+    session.showLocation()
+}
+
+// showLocation - Prints the instruction about to be executed
+func (session *debuggerSession) showLocation() {
+    session.printInstructions(session.debugger.Disassemble(session.debugger.Registers().PC, 1))
+}
+
+// printInstructions - Lists instructions, marking the one at PC and the breakpoints
+func (session *debuggerSession) printInstructions(instructions []gmb.DisassembledInstruction) {
+    pc := session.debugger.Registers().PC
+    breakpoints := map[uint16]bool{}
+    for _, breakpoint := range session.debugger.Breakpoints() {
+        if !breakpoint.AnyAddress {
+            breakpoints[breakpoint.Address] = true
+        }
+    }
+    for _, instruction := range instructions {
+        marker := "  "
+        if instruction.Address == pc {
+            marker = "=>"
+        }
+        if breakpoints[instruction.Address] {
+            marker = marker[:1] + "*"
+        }
+        fmt.Println(marker, instruction)
+    }
+}
+
+// setRegister - Changes one 8-bit register or register pair
+func (session *debuggerSession) setRegister(name string, value uint16) error {
+    registers := session.debugger.Registers()
+    bytes := map[string]*uint8{"A": &registers.A, "F": &registers.F, "B": &registers.B, "C": &registers.C,
+        "D": &registers.D, "E": &registers.E, "H": &registers.H, "L": &registers.L}
+    switch {
+    case bytes[name] != nil:
+        if value > 0xFF {
+            return fmt.Errorf("%s is an 8-bit register", name)
+        }
+        *bytes[name] = uint8(value)
+    case name == "AF" || name == "BC" || name == "DE" || name == "HL":
+        *bytes[name[:1]] = uint8(value >> 8)
+        *bytes[name[1:]] = uint8(value)
+    case name == "SP":
+        registers.SP = value
+    case name == "PC":
+        registers.PC = value
+    default:
+        return fmt.Errorf("Unknown register %q", name)
+    }
+    session.debugger.SetRegisters(registers)
+    return nil
+}
+
+// parseBreakpoint - Parses the arguments of the b and tp commands
+func parseBreakpoint(args []string) (gmb.Breakpoint, error) {
+    breakpoint := gmb.Breakpoint{AnyAddress: true}
+    if len(args) > 0 && args[0] != "if" && args[0] != "ignore" {
+        address, err := parseNumber(args[0])
+        if err != nil {
+            return breakpoint, err
+        }
+        breakpoint.Address, breakpoint.AnyAddress = uint16(address), false
+        args = args[1:]
+    }
+    if len(args) >= 2 && args[0] == "ignore" {
+        count, err := parseNumber(args[1])
+        if err != nil {
+            return breakpoint, fmt.Errorf("Invalid ignore count %q", args[1])
+        }
+        breakpoint.IgnoreCount = int(count)
+        args = args[2:]
+    }
+    if len(args) >= 2 && args[0] == "if" {
+        condition, err := gmb.ParseExpression(strings.Join(args[1:], " "))
+        if err != nil {
+            return breakpoint, err
+        }
+        breakpoint.Condition = condition
+        args = nil
+    }
+    if len(args) > 0 {
+        return breakpoint, fmt.Errorf("Usage: b [addr] [ignore <n>] [if <expression>]")
+    }
+    if breakpoint.AnyAddress && breakpoint.Condition == nil {
+        return breakpoint, fmt.Errorf("A breakpoint needs an address, a condition or both")
+    }
+    return breakpoint, nil
+}
+
+// parseWatchpoint - Parses the arguments of the wp command
+func parseWatchpoint(args []string) (gmb.Watchpoint, error) {
+    if len(args) == 0 {
+        return gmb.Watchpoint{}, fmt.Errorf("Missing address")
+    }
+    watchpoint := gmb.Watchpoint{Kind: gmb.WatchWrite}
+    bounds := strings.SplitN(args[0], "-", 2)
+    start, err := parseNumber(bounds[0])
+    if err != nil {
+        return watchpoint, err
+    }
+    end := start
+    if len(bounds) == 2 {
+        if end, err = parseNumber(bounds[1]); err != nil {
+            return watchpoint, err
+        }
+        if end < start {
+            return watchpoint, fmt.Errorf("The range %s ends before it starts", args[0])
+        }
+    }
+    watchpoint.Start, watchpoint.End = uint16(start), uint16(end)
+
+    for _, arg := range args[1:] {
+        switch {
+        case arg == "r":
+            watchpoint.Kind = gmb.WatchRead
+        case arg == "w":
+            watchpoint.Kind = gmb.WatchWrite
+        case arg == "rw":
+            watchpoint.Kind = gmb.WatchAccess
+        case arg == "changed":
+            watchpoint.Changed = true
+        case strings.HasPrefix(arg, "=="):
+            value, err := parseNumber(arg[2:])
+            if err != nil || value > 0xFF {
+                return watchpoint, fmt.Errorf("Invalid byte %q", arg[2:])
+            }
+            watchpoint.HasValue, watchpoint.Value = true, uint8(value)
+        default:
+            return watchpoint, fmt.Errorf("Unknown watchpoint option %q", arg)
+        }
+    }
+    return watchpoint, nil
+}
+
+// parseNumber - Parses a hexadecimal number with an optional 0x or $ prefix
+func parseNumber(text string) (uint64, error) {
+    trimmed := strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(text), "0x"), "$")
+    value, err := strconv.ParseUint(trimmed, 16, 16)
+    if err != nil {
+        return 0, fmt.Errorf("Invalid number %q", text)
+    }
+    return value, nil
+}
+
+// parseAddressArgument - Parses the first argument as an address
+func parseAddressArgument(args []string) (uint16, error) {
+    if len(args) == 0 {
+        return 0, fmt.Errorf("Missing address")
+    }
+    value, err := parseNumber(args[0])
+    return uint16(value), err
+}
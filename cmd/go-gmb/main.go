@@ -0,0 +1,303 @@
+package main
+
+import (
+    "errors"
+    "flag"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "os/signal"
+    "strings"
+    "github.com/hajimehoshi/ebiten"
+    "github.com/Insood/go-gmb/gmb"
+)
+
+// SCREENSCALE - How much to upscale the display to fit the monitor better
+var SCREENSCALE = float64(2)
+
+// REWINDINTERVAL - How many frames to run between rewind snapshots. Holding the rewind
+// key steps back one snapshot per tick, so this is also the rewind speed
+var REWINDINTERVAL = 2
+
+// SAVEINTERVAL - How many frames to run between writing battery-backed RAM to disk (~5 seconds)
+var SAVEINTERVAL = 300
+
+// settings - The command line flags
+type settings struct {
+    romName string
+    verbose bool // Show every instruction being executed
+    display bool // Run with a window instead of headless
+    palette gmb.Palette // Output colors of the four shades
+    recordAudio string // WAV file to record the sound to; empty for none
+    recordChannels gmb.Channels
+    frames int // Headless runs stop after this many frames; 0 runs until Ctrl+C
+    rewindSeconds float64
+}
+
+func startup() settings {
+    args := os.Args[1:]
+    if len(args) == 0 {
+        fmt.Printf("%s <romname> - Runs the ROM <romname>\n", os.Args[0])
+        fmt.Printf("%s info <romname> - Prints the cartridge header of <romname>\n", os.Args[0])
+        fmt.Printf("%s debug <romname> - Runs <romname> in the command line debugger\n", os.Args[0])
+        fmt.Printf("%s gdb <romname> [address] - Serves <romname> to GDB on address (default %s)\n", os.Args[0], GDBADDRESS)
+        os.Exit(0)
+    }
+
+    if args[0] == "info" && len(args) == 2 {
+        infoMain(args[1])
+        os.Exit(0)
+    }
+
+    if args[0] == "debug" && len(args) == 2 {
+        if err := debuggerMain(args[1]); err != nil {
+            fmt.Println(err)
+            os.Exit(1)
+        }
+        os.Exit(0)
+    }
+
+    if args[0] == "gdb" && (len(args) == 2 || len(args) == 3) {
+        address := GDBADDRESS
+        if len(args) == 3 {
+            address = args[2]
+        }
+        if err := gdbMain(args[1], address); err != nil {
+            fmt.Println(err)
+            os.Exit(1)
+        }
+        os.Exit(0)
+    }
+
+    // Parse command line flags
+    verboseFlag := flag.Bool("v", false, "Show every instruction being executed (slow)")
+    displayFlag := flag.Bool("d", true, "Shows a display")
+    paletteFlag := flag.String("palette", "gray", "Output colors: one of "+strings.Join(gmb.PaletteNames(), ", ")+
+        " or four RRGGBB colors from lightest to darkest, separated by commas")
+    recordFlag := flag.String("record-audio", "", "Record the sound to a WAV file")
+    rewindFlag := flag.Float64("rewind", 30, "Seconds of gameplay that can be rewound by holding R (0 turns rewinding off)")
+    framesFlag := flag.Int("frames", 0, "With -d=false, stop after this many frames (0 = run until Ctrl+C)")
+    channelsFlag := flag.String("record-channels", "all", "Channels to record: all, or a comma separated list of pulse1, pulse2, wave and noise")
+    flag.Parse()
+
+    palette, err := gmb.ParsePalette(*paletteFlag)
+    if err != nil {
+        fmt.Println(err)
+        os.Exit(2)
+    }
+    channels, err := gmb.ParseChannels(*channelsFlag)
+    if err != nil {
+        fmt.Println(err)
+        os.Exit(2)
+    }
+
+    return settings{romName: args[len(args)-1], verbose: *verboseFlag, display: *displayFlag, palette: palette,
+        recordAudio: *recordFlag, recordChannels: channels, frames: *framesFlag,
+        rewindSeconds: *rewindFlag}
+}
+
+func generateTitle() string {
+    return fmt.Sprintf("Go-GMB Emulator (%f) FPS",ebiten.CurrentFPS())
+}
+
+// infoMain - Prints the cartridge header of the ROM. Bad checksums are reported but not fatal
+func infoMain(romName string) {
+    rom, err := ioutil.ReadFile(romName)
+    if err != nil {
+        fmt.Println(romName, "is an invalid file. Could not open.")
+        os.Exit(1)
+    }
+    header, err := gmb.ParseHeader(rom)
+    if err != nil {
+        fmt.Printf("%s: %s\n", romName, err)
+        os.Exit(1)
+    }
+    fmt.Print(header)
+}
+
+// stopDiagnostic - Describes why the machine stopped along with the CPU state at the time
+func stopDiagnostic(gb *gmb.GameBoy, err error) error {
+    return fmt.Errorf("Emulation stopped: %s\n%s", err, gb.CPUState())
+}
+
+// flushSave - Writes the save file; there is nowhere to send the error so it is only printed
+func flushSave(gb *gmb.GameBoy) {
+    if err := gb.FlushSave(); err != nil {
+        fmt.Println("Could not write save file:", err)
+    }
+}
+
+// startRecording - Starts the --record-audio recording. The returned function finishes it
+func startRecording(gb *gmb.GameBoy, config settings) (func(), error) {
+    if config.recordAudio == "" {
+        return func() {}, nil
+    }
+    file, err := os.Create(config.recordAudio)
+    if err != nil {
+        return nil, err
+    }
+    if err := gb.RecordAudio(file, config.recordChannels); err != nil {
+        file.Close()
+        return nil, err
+    }
+    return func() {
+        if err := gb.StopRecording(); err != nil {
+            fmt.Println("Could not write", config.recordAudio+":", err)
+        }
+        file.Close()
+    }, nil
+}
+
+// errQuit - Returned from the frame loop to stop ebiten when the user interrupts the program
+var errQuit = errors.New("quit requested")
+
+// interruptChannel - Returns a channel which receives a value when the user hits Ctrl+C
+func interruptChannel() chan os.Signal {
+    interrupted := make(chan os.Signal, 1)
+    signal.Notify(interrupted, os.Interrupt)
+    return interrupted
+}
+
+// debugMain - This is the loop that will run when the program starts with -d=false
+// Display/sound are not enabled and the emulator runs as fast as possible
+func debugMain(config settings) error {
+    gb, err := gmb.Load(config.romName, gmb.WithDebug(config.verbose), gmb.WithDisplay(false), gmb.WithSerialOutput(os.Stdout))
+    if err != nil {
+        return err
+    }
+    defer flushSave(gb)
+    stopRecording, err := startRecording(gb, config)
+    if err != nil {
+        return err
+    }
+    defer stopRecording()
+    interrupted := interruptChannel()
+
+    for frames := 1; ; frames++ {
+        if err := gb.RunFrame(); err != nil {
+            return stopDiagnostic(gb, err)
+        }
+
+        // Once per frame (of emulated time), do the housekeeping
+        if frames%SAVEINTERVAL == 0 {
+            flushSave(gb)
+        }
+        if frames == config.frames {
+            return nil
+        }
+        select {
+        case <-interrupted:
+            return nil
+        default:
+        }
+    }
+}
+
+// heldButtons - Maps the keyboard to the Game Boy buttons
+//   Arrow keys = D-pad, X = A, Z = B, Enter = Start, Backspace = Select (R rewinds)
+// (Shift is the save state modifier, see handleStateKeys)
+func heldButtons() gmb.Buttons {
+    return gmb.Buttons{
+        A:      ebiten.IsKeyPressed(ebiten.KeyX),
+        B:      ebiten.IsKeyPressed(ebiten.KeyZ),
+        Select: ebiten.IsKeyPressed(ebiten.KeyBackspace),
+        Start:  ebiten.IsKeyPressed(ebiten.KeyEnter),
+        Right:  ebiten.IsKeyPressed(ebiten.KeyRight),
+        Left:   ebiten.IsKeyPressed(ebiten.KeyLeft),
+        Up:     ebiten.IsKeyPressed(ebiten.KeyUp),
+        Down:   ebiten.IsKeyPressed(ebiten.KeyDown),
+    }
+}
+
+// displayMain - This is the main emulator mode w/ a display & sound enabled
+func displayMain(config settings) error {
+    // Audio paces the emulator when it is available; otherwise ebiten's 60 ticks per second do
+    stream, audioErr := startAudio()
+    options := []gmb.Option{gmb.WithDebug(config.verbose), gmb.WithSerialOutput(os.Stdout), gmb.WithPalette(config.palette),
+        gmb.WithRewind(config.rewindSeconds, REWINDINTERVAL)}
+    if audioErr != nil {
+        fmt.Println("Sound is disabled:", audioErr)
+    } else {
+        options = append(options, gmb.WithSampleRate(AUDIOSAMPLERATE))
+    }
+
+    gb, err := gmb.Load(config.romName, options...)
+    if err != nil {
+        return err
+    }
+    defer flushSave(gb)
+    stopRecording, err := startRecording(gb, config)
+    if err != nil {
+        return err
+    }
+    defer stopRecording()
+    interrupted := interruptChannel()
+    frames := 0
+
+    runFrame := func() error {
+        gb.SetButtons(heldButtons())
+        if err := gb.RunFrame(); err != nil {
+            return stopDiagnostic(gb, err)
+        }
+        if stream != nil {
+            stream.write(gb.AudioSamples())
+        }
+
+        frames++
+        if frames%SAVEINTERVAL == 0 {
+            flushSave(gb)
+        }
+        return nil
+    }
+
+    f := func(screen *ebiten.Image) error {
+        select {
+        case <-interrupted:
+            return errQuit
+        default:
+        }
+        handleStateKeys(gb, config.romName)
+
+        if ebiten.IsKeyPressed(ebiten.KeyR) {
+            gb.StepBack() // Simply stays put once the buffer runs out
+        } else if stream == nil {
+            if err := runFrame(); err != nil {
+                return err
+            }
+        } else {
+            // Emulate as many frames as the audio queue needs; 0 when ebiten ticks faster than 59.73Hz
+            for i := 0; i < MAXFRAMESPERTICK && stream.needsFrames(); i++ {
+                if err := runFrame(); err != nil {
+                    return err
+                }
+            }
+        }
+        screen.ReplacePixels(gb.Framebuffer().Pix)
+
+        ebiten.SetWindowTitle(generateTitle())
+        return nil
+    }
+
+    // Setup the main loop
+    ebiten.SetRunnableInBackground(true)
+    runErr := ebiten.Run(f, int(gmb.LCDWIDTH), int(gmb.LCDHEIGHT), SCREENSCALE, "Go-GMB Emulator")
+    if runErr == errQuit {
+        return nil
+    }
+    return runErr
+}
+
+func main() {
+    config := startup()
+
+    var err error
+    if config.display {
+        err = displayMain(config)
+    } else {
+        err = debugMain(config)
+    }
+    if err != nil {
+        fmt.Println(err)
+        os.Exit(1)
+    }
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+    "github.com/hajimehoshi/ebiten"
+    "github.com/hajimehoshi/ebiten/inpututil"
+    "github.com/Insood/go-gmb/gmb"
+)
+
+// stateKeys - F1-F10 select save state slots 1-10
+var stateKeys = []ebiten.Key{
+    ebiten.KeyF1, ebiten.KeyF2, ebiten.KeyF3, ebiten.KeyF4, ebiten.KeyF5,
+    ebiten.KeyF6, ebiten.KeyF7, ebiten.KeyF8, ebiten.KeyF9, ebiten.KeyF10,
+}
+
+// statePath - Slot N of game.gb is kept in game.ssN next to the ROM
+func statePath(romName string, slot int) string {
+    return fmt.Sprintf("%s.ss%d", strings.TrimSuffix(romName, filepath.Ext(romName)), slot)
+}
+
+// handleStateKeys - Shift+F1-F10 saves to a slot, F1-F10 loads it again
+func handleStateKeys(gb *gmb.GameBoy, romName string) {
+    for i, key := range stateKeys {
+        if !inpututil.IsKeyJustPressed(key) {
+            continue
+        }
+        path := statePath(romName, i+1)
+        if ebiten.IsKeyPressed(ebiten.KeyShift) {
+            if err := saveStateFile(gb, path); err != nil {
+                fmt.Println("Could not save state:", err)
+            } else {
+                fmt.Println("Saved state", i+1)
+            }
+        } else {
+            if err := loadStateFile(gb, path); err != nil {
+                fmt.Println("Could not load state:", err)
+            } else {
+                fmt.Println("Loaded state", i+1)
+            }
+        }
+    }
+}
+
+func saveStateFile(gb *gmb.GameBoy, path string) error {
+    file, err := os.Create(path)
+    if err != nil {
+        return err
+    }
+    if err := gb.SaveState(file); err != nil {
+        file.Close()
+        return err
+    }
+    return file.Close()
+}
+
+func loadStateFile(gb *gmb.GameBoy, path string) error {
+    file, err := os.Open(path)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+    return gb.LoadState(file)
+}
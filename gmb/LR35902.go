@@ -1,4 +1,4 @@
-package main
+package gmb
 
 import (
     "fmt"
@@ -35,10 +35,13 @@ type CPU struct {
     inte      bool // Whether or not interrupts are enabled
 
     branchNotTaken bool
+    haltBug bool // The next opcode is fetched without incrementing PC (see halt)
+    err error // Set by an instruction which could not be executed. Returned by step
 
     // The following are not part of the microcontroller spec, but are here to help
     // with the emulation
     instructionsExecuted uint64
+    debugMode bool // Pretty print every instruction as it is executed
 }
 
 func (cpu *CPU) pswByte() uint8 {
@@ -579,7 +582,14 @@ func (cpu *CPU) CheckCondition() bool {
 }
 
 func (cpu *CPU) currentInstruction() uint8 {
-    return cpu.mmu.read8(cpu.programCounter)
+    return cpu.mmu.read8(cpu.currentInstructionAddress())
+}
+// currentInstructionAddress - The address the current opcode is fetched from
+func (cpu *CPU) currentInstructionAddress() uint16 {
+    if cpu.haltBug { // PC is still pointing at the HALT
+        return cpu.programCounter+1
+    }
+    return cpu.programCounter
 }
 func (cpu *CPU) nextInstruction() uint8 {
     return cpu.mmu.read8(cpu.programCounter+1)
@@ -836,16 +846,18 @@ func ei(cpu * CPU){
 }
 // halt - halts execution until an interrupt fires
 // Only has an effect if interrupts have been enabled through EI
-// There is a bug with HALT that needs to be properly emulated if
-// if it is called with DI disabled
+// If HALT is called with interrupts disabled while an interrupt is already pending,
+// the CPU does not halt and fails to increase PC when fetching the next opcode.
+// The byte after HALT is read twice: once as the opcode and once more as the next byte
+// (ie: the first operand, or the next opcode for 1-byte instructions).
+// This is emulated by leaving PC on the HALT and fetching the opcode from PC+1
 // Lots of helpful information here: https://github.com/AntonioND/giibiiadvance/tree/master/docs
 func halt(cpu * CPU){
     if cpu.inte {
         cpu.halted = true
     } else {
         if (cpu.mmu.getIE() & cpu.mmu.getIF()) != 0 {
-            // CPU should fail to increase PC and also not clear IF flags
-            panic("Unhandled halt bug")
+            cpu.haltBug = true // IF flags are not cleared either
         } else {
             cpu.halted = true
         }
@@ -1497,14 +1509,14 @@ func xord8(cpu *CPU) {
     cpu.programCounter += 2
 }
 
+// unimplemented - Placeholder for the opcodes which do not exist on the LR35902
+// The CPU stops and step() returns an ErrIllegalOpcode
 func unimplemented(cpu *CPU) {
-    errStr := fmt.Sprintf("Instruction [%X] is not yet implemented", cpu.currentInstruction())
-    panic(errStr)
+    cpu.err = &ErrIllegalOpcode{cpu.currentInstructionAddress(), cpu.currentInstruction(), false}
 }
 
 func unimplementedExtended(cpu *CPU) {
-    errStr := fmt.Sprintf("Extended Instruction [CB %X] is not yet implemented", cpu.currentInstruction())
-    panic(errStr)
+    cpu.err = &ErrIllegalOpcode{cpu.programCounter-1, cpu.currentInstruction(), true}
 }
 
 func (cpu * CPU) interrupt(interrupt uint8, address uint16){
@@ -1543,8 +1555,8 @@ func (cpu * CPU) checkForInterrupts(){
             cpu.interrupt(0x4, 0x50)
         } else if ((interruptFlag & 8) > 0) && ((interruptEnabledFlag & 0x8) > 0) { // Serial transfer
             cpu.interrupt(0x8,0x58)
-        } else if ((interruptFlag & 16) > 0) && ((interruptEnabledFlag & 0x16) >0) { // Hi-Lo of P10-P13 (button input)
-            cpu.interrupt(0x16, 0x60)
+        } else if ((interruptFlag & 16) > 0) && ((interruptEnabledFlag & 0x10) >0) { // Hi-Lo of P10-P13 (button input)
+            cpu.interrupt(0x10, 0x60)
         }
     } else {
         // Special code for handling HALT that was called when interrupts are not enabled
@@ -1584,10 +1596,25 @@ func (cpu * CPU) cyclesThisStep(currenttInstruction Instruction) int {
     return currenttInstruction.cycles
 }
 
-func (cpu *CPU) step() int {
+// updateHardware - Advances everything which is clocked alongside the CPU
+func (cpu *CPU) updateHardware(cycles int) {
+    cpu.timer.update(cycles)
+    cpu.mmu.updateDMA(cycles)
+    cpu.mmu.apu.update(cycles)
+}
+
+// step - Executes a single instruction and returns the number of cycles it took
+// An error is returned if the instruction could not be executed; the CPU state
+// is left as it was right before the faulting instruction
+func (cpu *CPU) step() (int, error) {
+    if cpu.err != nil { // The CPU is locked up
+        return 0, cpu.err
+    }
+
     prettyDebugOutputAboutCurrentInstruction(cpu)
     cyclesThisStep := 0
     if !cpu.halted{
+        haltBug := cpu.haltBug // Only lasts for this instruction
         instruction := cpu.currentInstruction()
         instructionInfo := Instruction{}
 
@@ -1595,21 +1622,28 @@ func (cpu *CPU) step() int {
             instructionInfo = cpu.mainInstructions[instruction]
         } else {
             cpu.programCounter++
+            cpu.haltBug = false
             instruction := cpu.currentInstruction()
             instructionInfo = cpu.extendedInstructions[instruction]
         }
 
         instructionInfo.function(cpu) // Execute the instruction
+        if haltBug {
+            cpu.haltBug = false
+        }
+        if cpu.err != nil {
+            return 0, cpu.err
+        }
         cpu.instructionsExecuted++
         cyclesThisStep = cpu.cyclesThisStep(instructionInfo)
-        cpu.timer.update(cyclesThisStep) // Update the timers (which may trigger interrupts)
+        cpu.updateHardware(cyclesThisStep) // Update the timers (which may trigger interrupts)
     } else {
         // Special code to handle what to do if the CPU is halted
         // During a halt, the CPU is executing 4 cycles every update
         instructionInfo := cpu.mainInstructions[cpu.currentInstruction()]
         cyclesThisStep = cpu.cyclesThisStep(instructionInfo)
-        cpu.timer.update(cyclesThisStep) 
+        cpu.updateHardware(cyclesThisStep)
     }
 
-    return cyclesThisStep
+    return cyclesThisStep, nil
 }
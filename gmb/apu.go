@@ -0,0 +1,361 @@
+package gmb
+
+import (
+    "fmt"
+    "io"
+    "math"
+    "strings"
+)
+
+// CLOCKSPEED - Clock cycles per second
+const CLOCKSPEED = 4194304
+
+// APU - The audio processing unit. Four channels are mixed into a stereo signal
+//
+//   0xFF10-0xFF14  NR10-NR14  Channel 1: pulse with frequency sweep
+//   0xFF16-0xFF19  NR21-NR24  Channel 2: pulse
+//   0xFF1A-0xFF1E  NR30-NR34  Channel 3: wave
+//   0xFF20-0xFF23  NR41-NR44  Channel 4: noise
+//   0xFF24         NR50       Master volume (bits 6-4 left, bits 2-0 right)
+//   0xFF25         NR51       Panning (bits 7-4 left, bits 3-0 right; one bit per channel)
+//   0xFF26         NR52       Bit 7 powers the APU; bits 3-0 report which channels are playing
+//   0xFF30-0xFF3F             Wave RAM: 32 4-bit samples, high nibble first
+//
+// The length counters, volume envelopes and sweep are clocked by the frame sequencer,
+// which steps at 512Hz whenever bit 4 of DIV goes from 1 to 0
+type APU struct {
+    registers [0x30]uint8 // Raw register values for 0xFF10-0xFF3F
+    pulse1 PulseChannel
+    pulse2 PulseChannel
+    wave WaveChannel
+    noise NoiseChannel
+    powered bool
+    frameSequencerStep int
+
+    sampleRate int // Samples per second per channel. 0 turns off sample generation
+    cyclesPerSample float64
+    cyclesUntilSample float64
+    bufferSamples bool // Keep samples for takeSamples; false when only recording
+    samples []int16 // Interleaved left/right samples which have not been taken yet
+    filter highPassFilter
+    capacitorCharge float64
+    recorder *audioRecorder // Nil unless a WAV recording is running
+}
+
+// Channels - A set of APU channels, used to record channels in isolation
+type Channels uint8
+
+// The four APU channels
+const (
+    Pulse1 Channels = 1 << iota
+    Pulse2
+    Wave
+    Noise
+    AllChannels = Pulse1 | Pulse2 | Wave | Noise
+)
+
+// channelNames - The names accepted by ParseChannels
+var channelNames = map[string]Channels{"pulse1": Pulse1, "pulse2": Pulse2, "wave": Wave, "noise": Noise, "all": AllChannels}
+
+// ParseChannels - Parses a comma separated list of channel names (pulse1, pulse2, wave, noise or all)
+func ParseChannels(description string) (Channels, error) {
+    channels := Channels(0)
+    for _, name := range strings.Split(description, ",") {
+        channel, ok := channelNames[strings.TrimSpace(strings.ToLower(name))]
+        if !ok {
+            return 0, fmt.Errorf("unknown sound channel %q: expected pulse1, pulse2, wave, noise or all", name)
+        }
+        channels |= channel
+    }
+    return channels, nil
+}
+
+// DEFAULTSAMPLERATE - Used for recordings when no WithSampleRate was given
+const DEFAULTSAMPLERATE = 44100
+
+// highPassFilter - The output capacitors of the Game Boy, which slowly remove any DC offset
+type highPassFilter struct {
+    capacitorLeft float64
+    capacitorRight float64
+}
+
+// audioRecorder - Writes its own mix of the selected channels to a WAV file
+type audioRecorder struct {
+    wav *WAVWriter
+    channels Channels
+    filter highPassFilter
+}
+
+// apuReadMasks - Bits which always read back as 1 (write only or unused bits) for 0xFF10-0xFF2F
+var apuReadMasks = [0x20]uint8{
+    0x80, 0x3F, 0x00, 0xFF, 0xBF, // NR10-NR14
+    0xFF, 0x3F, 0x00, 0xFF, 0xBF, // unused, NR21-NR24
+    0x7F, 0xFF, 0x9F, 0xFF, 0xBF, // NR30-NR34
+    0xFF, 0xFF, 0x00, 0x00, 0xBF, // unused, NR41-NR44
+    0x00, 0x00, 0x70, // NR50-NR52
+    0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, // unused
+}
+
+// read - Reads one of the sound registers or wave RAM (0xFF10-0xFF3F)
+func (apu *APU) read(address uint16) uint8 {
+    if address >= 0xFF30 {
+        return apu.registers[address-0xFF10]
+    }
+    if address == 0xFF26 {
+        status := uint8(0x70)
+        if apu.powered {
+            status |= 0x80
+        }
+        for i, enabled := range []bool{apu.pulse1.enabled, apu.pulse2.enabled, apu.wave.enabled, apu.noise.enabled} {
+            if enabled {
+                status |= 1 << uint(i)
+            }
+        }
+        return status
+    }
+    return apu.registers[address-0xFF10] | apuReadMasks[address-0xFF10]
+}
+
+// write - Writes one of the sound registers or wave RAM (0xFF10-0xFF3F).
+// While the APU is powered off only NR52 and wave RAM can be written
+func (apu *APU) write(address uint16, data uint8) {
+    if address >= 0xFF30 {
+        apu.registers[address-0xFF10] = data
+        return
+    }
+    if address == 0xFF26 {
+        apu.setPower(data & 0x80 != 0)
+        return
+    }
+    if !apu.powered {
+        return
+    }
+
+    apu.registers[address-0xFF10] = data
+    switch {
+    case address <= 0xFF14:
+        apu.pulse1.write(address-0xFF10, data)
+    case address >= 0xFF16 && address <= 0xFF19:
+        apu.pulse2.write(address-0xFF15, data)
+    case address >= 0xFF1A && address <= 0xFF1E:
+        apu.wave.write(address-0xFF1A, data)
+    case address >= 0xFF20 && address <= 0xFF23:
+        apu.noise.write(address-0xFF1F, data)
+    }
+}
+
+// setPower - Turning the APU off clears every register except wave RAM and silences all channels
+func (apu *APU) setPower(on bool) {
+    if apu.powered == on {
+        return
+    }
+    apu.powered = on
+    if !on {
+        for i := 0; i < 0x20; i++ {
+            apu.registers[i] = 0
+        }
+        apu.pulse1 = PulseChannel{hasSweep: true}
+        apu.pulse2 = PulseChannel{}
+        apu.wave = WaveChannel{}
+        apu.noise = NoiseChannel{}
+    } else {
+        apu.frameSequencerStep = 0
+    }
+}
+
+// clockFrameSequencer - Called on every falling edge of DIV bit 4 (512Hz)
+//
+//   Step    0  1  2  3  4  5  6  7
+//   Length  x     x     x     x
+//   Sweep         x           x
+//   Volume                       x
+func (apu *APU) clockFrameSequencer() {
+    if !apu.powered {
+        return
+    }
+    step := apu.frameSequencerStep
+    if step % 2 == 0 {
+        apu.pulse1.length.clock(&apu.pulse1.enabled)
+        apu.pulse2.length.clock(&apu.pulse2.enabled)
+        apu.wave.length.clock(&apu.wave.enabled)
+        apu.noise.length.clock(&apu.noise.enabled)
+    }
+    if step == 2 || step == 6 {
+        apu.pulse1.clockSweep()
+    }
+    if step == 7 {
+        apu.pulse1.envelope.clock()
+        apu.pulse2.envelope.clock()
+        apu.noise.envelope.clock()
+    }
+    apu.frameSequencerStep = (step + 1) % 8
+}
+
+// update - Runs the channels for the number of cycles that were performed and
+// produces the samples which fell into that time
+func (apu *APU) update(cyclesPerformed int) {
+    if apu.sampleRate == 0 {
+        apu.tick(cyclesPerformed)
+        return
+    }
+
+    // Run the channels up to each sample point so that every sample sees the right state
+    remaining := cyclesPerformed
+    for remaining > 0 {
+        cycles := int(math.Ceil(apu.cyclesUntilSample))
+        if cycles > remaining {
+            cycles = remaining
+        }
+        if cycles < 1 {
+            cycles = 1
+        }
+        apu.tick(cycles)
+        remaining -= cycles
+        apu.cyclesUntilSample -= float64(cycles)
+        if apu.cyclesUntilSample <= 0 {
+            apu.cyclesUntilSample += apu.cyclesPerSample
+            apu.mix()
+        }
+    }
+}
+
+// tick - Advances the frequency timers of all channels
+func (apu *APU) tick(cycles int) {
+    if !apu.powered {
+        return
+    }
+    apu.pulse1.tick(cycles)
+    apu.pulse2.tick(cycles)
+    apu.wave.tick(cycles, &apu.registers)
+    apu.noise.tick(cycles)
+}
+
+// channelOutputs - The analog output (-1 to 1) of every channel
+func (apu *APU) channelOutputs() [4]float64 {
+    return [4]float64{
+        dacOutput(apu.pulse1.dacEnabled(), apu.pulse1.enabled, apu.pulse1.output()),
+        dacOutput(apu.pulse2.dacEnabled(), apu.pulse2.enabled, apu.pulse2.output()),
+        dacOutput(apu.wave.dacEnabled, apu.wave.enabled, apu.wave.output()),
+        dacOutput(apu.noise.dacEnabled(), apu.noise.enabled, apu.noise.output()),
+    }
+}
+
+// dacOutput - Converts a 4-bit digital channel output into an analog level
+func dacOutput(dacEnabled bool, enabled bool, digital uint8) float64 {
+    if !dacEnabled || !enabled {
+        return 0
+    }
+    return float64(digital)/7.5 - 1
+}
+
+// mix - Produces one stereo sample for playback and one for the recording (if any)
+func (apu *APU) mix() {
+    if apu.bufferSamples {
+        left, right := apu.mixChannels(AllChannels)
+        outLeft, outRight := apu.filter.filter(left, right, apu.capacitorCharge)
+        apu.samples = append(apu.samples, outLeft, outRight)
+    }
+    if apu.recorder != nil {
+        left, right := apu.mixChannels(apu.recorder.channels)
+        outLeft, outRight := apu.recorder.filter.filter(left, right, apu.capacitorCharge)
+        apu.recorder.wav.WriteSamples([]int16{outLeft, outRight}) // Errors are kept until the recording stops
+    }
+}
+
+// mixChannels - Mixes the selected channels into a single stereo sample using NR50/NR51
+func (apu *APU) mixChannels(channels Channels) (float64, float64) {
+    left, right := 0.0, 0.0
+    if !apu.powered {
+        return left, right
+    }
+    panning := apu.registers[0x15]
+    volume := apu.registers[0x14]
+    for i, output := range apu.channelOutputs() {
+        if channels & (1 << uint(i)) == 0 {
+            continue
+        }
+        if panning & (0x10 << uint(i)) != 0 {
+            left += output
+        }
+        if panning & (0x01 << uint(i)) != 0 {
+            right += output
+        }
+    }
+    left *= float64((volume >> 4) & 0x7 + 1) / 8 / 4
+    right *= float64(volume & 0x7 + 1) / 8 / 4
+    return left, right
+}
+
+// filter - Runs a stereo sample through the capacitors and converts it to 16 bits
+func (filter *highPassFilter) filter(left float64, right float64, charge float64) (int16, int16) {
+    outLeft := left - filter.capacitorLeft
+    filter.capacitorLeft = left - outLeft*charge
+    outRight := right - filter.capacitorRight
+    filter.capacitorRight = right - outRight*charge
+    return toInt16(outLeft), toInt16(outRight)
+}
+
+func toInt16(sample float64) int16 {
+    sample = math.Max(-1, math.Min(1, sample))
+    return int16(sample * 32767)
+}
+
+// setSampleRate - Changes how many stereo samples are produced per second (0 = none)
+func (apu *APU) setSampleRate(rate int) {
+    apu.sampleRate = rate
+    apu.bufferSamples = rate != 0
+    apu.samples = nil
+    if rate == 0 {
+        return
+    }
+    apu.cyclesPerSample = float64(CLOCKSPEED) / float64(rate)
+    apu.cyclesUntilSample = apu.cyclesPerSample
+    apu.capacitorCharge = math.Pow(0.999958, apu.cyclesPerSample)
+}
+
+// startRecording - Streams the selected channels to a WAV file at the sample rate
+// (DEFAULTSAMPLERATE if samples were not being produced yet)
+func (apu *APU) startRecording(writer io.WriteSeeker, channels Channels) error {
+    if apu.sampleRate == 0 {
+        apu.setSampleRate(DEFAULTSAMPLERATE)
+        apu.bufferSamples = false // Nobody is taking the samples
+    }
+    wav, err := NewWAVWriter(writer, apu.sampleRate)
+    if err != nil {
+        return err
+    }
+    apu.recorder = &audioRecorder{wav: wav, channels: channels}
+    return nil
+}
+
+// resumeRecording - Continues a recording of another APU (which ran at rate) after a reset
+func (apu *APU) resumeRecording(recorder *audioRecorder, rate int) {
+    if apu.sampleRate == 0 {
+        apu.setSampleRate(rate)
+        apu.bufferSamples = false
+    }
+    apu.recorder = recorder
+}
+
+// stopRecording - Finishes the WAV file. Returns the first error from the whole recording
+func (apu *APU) stopRecording() error {
+    if apu.recorder == nil {
+        return nil
+    }
+    err := apu.recorder.wav.Close()
+    apu.recorder = nil
+    return err
+}
+
+// takeSamples - Returns the samples produced so far and empties the buffer
+func (apu *APU) takeSamples() []int16 {
+    samples := apu.samples
+    apu.samples = nil
+    return samples
+}
+
+func newAPU() *APU {
+    apu := new(APU)
+    apu.pulse1.hasSweep = true
+    return apu
+}
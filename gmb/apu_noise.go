@@ -0,0 +1,71 @@
+package gmb
+
+// noiseDivisors - The base periods selected by NR43 bits 2-0
+var noiseDivisors = [8]int{8, 16, 32, 48, 64, 80, 96, 112}
+
+// NoiseChannel - Channel 4 outputs the low bit of a linear feedback shift register
+//
+//   NR41  --LL LLLL  Length load (64-L)
+//   NR42  VVVV APPP  Envelope
+//   NR43  SSSS WDDD  Clock shift, width mode (1 = 7-bit LFSR), divisor code
+//   NR44  TL-- ----  Trigger, length enable
+type NoiseChannel struct {
+    enabled bool
+    polynomial uint8 // NR43
+    lfsr uint16
+    timer int
+    length LengthCounter
+    envelope VolumeEnvelope
+}
+
+// write - register is 1 for NR41 through 4 for NR44
+func (noise *NoiseChannel) write(register uint16, data uint8) {
+    noise.length.maximum = 64
+    switch register {
+    case 1:
+        noise.length.load(int(data & 0x3F))
+    case 2:
+        noise.envelope.register = data
+        if !noise.dacEnabled() {
+            noise.enabled = false
+        }
+    case 3:
+        noise.polynomial = data
+    case 4:
+        noise.length.enabled = data & 0x40 != 0
+        if data & 0x80 != 0 {
+            noise.enabled = noise.dacEnabled()
+            noise.length.trigger()
+            noise.envelope.trigger()
+            noise.timer = noise.period()
+            noise.lfsr = 0x7FFF
+        }
+    }
+}
+
+// period - divisor << shift cycles between LFSR shifts
+func (noise *NoiseChannel) period() int {
+    return noiseDivisors[noise.polynomial & 0x7] << (noise.polynomial >> 4)
+}
+
+// tick - Shifts the LFSR. The XOR of the two low bits is fed into bit 14 (and bit 6 in 7-bit mode)
+func (noise *NoiseChannel) tick(cycles int) {
+    noise.timer -= cycles
+    for noise.timer <= 0 {
+        noise.timer += noise.period()
+        feedback := (noise.lfsr ^ (noise.lfsr >> 1)) & 0x1
+        noise.lfsr = (noise.lfsr >> 1) | (feedback << 14)
+        if noise.polynomial & 0x8 != 0 {
+            noise.lfsr = (noise.lfsr &^ 0x40) | (feedback << 6)
+        }
+    }
+}
+
+func (noise *NoiseChannel) dacEnabled() bool {
+    return noise.envelope.dacEnabled()
+}
+
+// output - The digital output (0-15); the inverted low bit of the LFSR
+func (noise *NoiseChannel) output() uint8 {
+    return uint8(^noise.lfsr & 0x1) * noise.envelope.volume
+}
@@ -0,0 +1,198 @@
+package gmb
+
+// LengthCounter - Silences a channel once it has been clocked down to zero (if enabled)
+type LengthCounter struct {
+    counter int
+    enabled bool
+    maximum int // 64, or 256 for the wave channel
+}
+
+// clock - Called at 256Hz by the frame sequencer
+func (length *LengthCounter) clock(channelEnabled *bool) {
+    if length.enabled && length.counter > 0 {
+        length.counter--
+        if length.counter == 0 {
+            *channelEnabled = false
+        }
+    }
+}
+
+// load - Writing the length register sets the counter to maximum-data
+func (length *LengthCounter) load(data int) {
+    length.counter = length.maximum - data
+}
+
+// trigger - A triggered channel with an expired length counter plays for the full length
+func (length *LengthCounter) trigger() {
+    if length.counter == 0 {
+        length.counter = length.maximum
+    }
+}
+
+// VolumeEnvelope - NRx2: the starting volume (bits 7-4), direction (bit 3; 1=up) and
+// period (bits 2-0) in 64Hz steps. A period of 0 keeps the volume constant
+type VolumeEnvelope struct {
+    register uint8
+    volume uint8
+    timer int
+}
+
+// clock - Called at 64Hz by the frame sequencer
+func (envelope *VolumeEnvelope) clock() {
+    period := int(envelope.register & 0x7)
+    if period == 0 {
+        return
+    }
+    envelope.timer--
+    if envelope.timer > 0 {
+        return
+    }
+    envelope.timer = period
+    if envelope.register & 0x8 != 0 && envelope.volume < 15 {
+        envelope.volume++
+    } else if envelope.register & 0x8 == 0 && envelope.volume > 0 {
+        envelope.volume--
+    }
+}
+
+func (envelope *VolumeEnvelope) trigger() {
+    envelope.volume = envelope.register >> 4
+    envelope.timer = int(envelope.register & 0x7)
+}
+
+// dacEnabled - The DAC of a channel with an envelope is off when the top 5 bits of NRx2 are 0
+func (envelope *VolumeEnvelope) dacEnabled() bool {
+    return envelope.register & 0xF8 != 0
+}
+
+// dutyPatterns - The 8-step waveforms for 12.5%, 25%, 50% and 75% duty
+var dutyPatterns = [4][8]uint8{
+    {0, 0, 0, 0, 0, 0, 0, 1},
+    {1, 0, 0, 0, 0, 0, 0, 1},
+    {1, 0, 0, 0, 0, 1, 1, 1},
+    {0, 1, 1, 1, 1, 1, 1, 0},
+}
+
+// PulseChannel - Channels 1 and 2. Only channel 1 has the frequency sweep
+//
+//   NR10  -PPP NSSS  Sweep period, negate, shift
+//   NRx1  DDLL LLLL  Duty, length load (64-L)
+//   NRx2  VVVV APPP  Envelope
+//   NRx3  FFFF FFFF  Frequency low bits
+//   NRx4  TL-- -FFF  Trigger, length enable, frequency high bits
+type PulseChannel struct {
+    enabled bool
+    hasSweep bool
+    duty uint8
+    dutyStep int
+    frequency int
+    timer int
+    length LengthCounter
+    envelope VolumeEnvelope
+
+    sweepRegister uint8
+    sweepEnabled bool
+    sweepTimer int
+    shadowFrequency int
+}
+
+// write - register is 0 for NRx0 through 4 for NRx4
+func (pulse *PulseChannel) write(register uint16, data uint8) {
+    pulse.length.maximum = 64
+    switch register {
+    case 0:
+        pulse.sweepRegister = data
+    case 1:
+        pulse.duty = data >> 6
+        pulse.length.load(int(data & 0x3F))
+    case 2:
+        pulse.envelope.register = data
+        if !pulse.dacEnabled() {
+            pulse.enabled = false
+        }
+    case 3:
+        pulse.frequency = (pulse.frequency & 0x700) | int(data)
+    case 4:
+        pulse.frequency = (pulse.frequency & 0xFF) | int(data & 0x7) << 8
+        pulse.length.enabled = data & 0x40 != 0
+        if data & 0x80 != 0 {
+            pulse.trigger()
+        }
+    }
+}
+
+func (pulse *PulseChannel) trigger() {
+    pulse.enabled = pulse.dacEnabled()
+    pulse.length.trigger()
+    pulse.timer = (2048 - pulse.frequency) * 4
+    pulse.envelope.trigger()
+
+    if pulse.hasSweep {
+        pulse.shadowFrequency = pulse.frequency
+        pulse.sweepTimer = pulse.sweepPeriod()
+        shift := pulse.sweepRegister & 0x7
+        pulse.sweepEnabled = pulse.sweepRegister & 0x70 != 0 || shift != 0
+        if shift != 0 {
+            pulse.nextSweepFrequency() // Only checks for overflow
+        }
+    }
+}
+
+// sweepPeriod - A period of 0 is treated as 8 by the sweep timer
+func (pulse *PulseChannel) sweepPeriod() int {
+    period := int(pulse.sweepRegister >> 4) & 0x7
+    if period == 0 {
+        return 8
+    }
+    return period
+}
+
+// nextSweepFrequency - Calculates the swept frequency and disables the channel when it overflows
+func (pulse *PulseChannel) nextSweepFrequency() int {
+    delta := pulse.shadowFrequency >> (pulse.sweepRegister & 0x7)
+    frequency := pulse.shadowFrequency + delta
+    if pulse.sweepRegister & 0x8 != 0 {
+        frequency = pulse.shadowFrequency - delta
+    }
+    if frequency > 2047 {
+        pulse.enabled = false
+    }
+    return frequency
+}
+
+// clockSweep - Called at 128Hz by the frame sequencer
+func (pulse *PulseChannel) clockSweep() {
+    pulse.sweepTimer--
+    if pulse.sweepTimer > 0 {
+        return
+    }
+    pulse.sweepTimer = pulse.sweepPeriod()
+    if !pulse.sweepEnabled || pulse.sweepRegister & 0x70 == 0 {
+        return
+    }
+
+    frequency := pulse.nextSweepFrequency()
+    if frequency <= 2047 && pulse.sweepRegister & 0x7 != 0 {
+        pulse.shadowFrequency = frequency
+        pulse.frequency = frequency
+        pulse.nextSweepFrequency() // The new frequency is checked for overflow again
+    }
+}
+
+// tick - Advances the duty cycle; one step every (2048-frequency)*4 cycles
+func (pulse *PulseChannel) tick(cycles int) {
+    pulse.timer -= cycles
+    for pulse.timer <= 0 {
+        pulse.timer += (2048 - pulse.frequency) * 4
+        pulse.dutyStep = (pulse.dutyStep + 1) % 8
+    }
+}
+
+func (pulse *PulseChannel) dacEnabled() bool {
+    return pulse.envelope.dacEnabled()
+}
+
+// output - The digital output (0-15)
+func (pulse *PulseChannel) output() uint8 {
+    return dutyPatterns[pulse.duty][pulse.dutyStep] * pulse.envelope.volume
+}
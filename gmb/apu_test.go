@@ -0,0 +1,116 @@
+package gmb
+
+import "testing"
+
+func poweredAPU() *APU {
+    apu := newAPU()
+    apu.write(0xFF26, 0x80)
+    return apu
+}
+
+func TestAPURegisters(t *testing.T) {
+    apu := newAPU()
+    apu.write(0xFF12, 0xF3)
+    if apu.read(0xFF12) != 0x00 {
+        t.Errorf("Registers should not be writable while the APU is off")
+    }
+    apu.write(0xFF30, 0x12)
+    if apu.read(0xFF30) != 0x12 {
+        t.Errorf("Wave RAM should be writable while the APU is off")
+    }
+
+    apu.write(0xFF26, 0x80)
+    apu.write(0xFF10, 0x12)
+    apu.write(0xFF11, 0x85)
+    apu.write(0xFF13, 0x34)
+    if apu.read(0xFF10) != 0x92 || apu.read(0xFF11) != 0xBF || apu.read(0xFF13) != 0xFF {
+        t.Errorf("Write only and unused bits should read as 1: %02X %02X %02X", apu.read(0xFF10), apu.read(0xFF11), apu.read(0xFF13))
+    }
+    if apu.read(0xFF26) != 0xF0 {
+        t.Errorf("NR52 should read F0 with no channels playing, got %02X", apu.read(0xFF26))
+    }
+
+    apu.write(0xFF26, 0x00)
+    if apu.read(0xFF10) != 0x80 || apu.read(0xFF30) != 0x12 {
+        t.Errorf("Powering off should clear the registers but keep wave RAM")
+    }
+}
+
+func TestAPULengthCounter(t *testing.T) {
+    apu := poweredAPU()
+    apu.write(0xFF21, 0xF0) // Noise DAC on
+    apu.write(0xFF20, 62)   // Two length clocks
+    apu.write(0xFF23, 0xC0) // Trigger with length enabled
+    if apu.read(0xFF26) & 0x8 == 0 {
+        t.Errorf("Triggering channel 4 should set NR52 bit 3")
+    }
+    apu.clockFrameSequencer() // Step 0: length
+    apu.clockFrameSequencer() // Step 1
+    if !apu.noise.enabled {
+        t.Errorf("Channel should still be playing after one length clock")
+    }
+    apu.clockFrameSequencer() // Step 2: length
+    if apu.noise.enabled {
+        t.Errorf("Channel should stop when the length counter runs out")
+    }
+}
+
+func TestAPUSweepOverflow(t *testing.T) {
+    apu := poweredAPU()
+    apu.write(0xFF10, 0x11) // Period 1, add, shift 1
+    apu.write(0xFF12, 0xF0)
+    apu.write(0xFF13, 0x00)
+    apu.write(0xFF14, 0x85) // Frequency 0x500 + 0x280 is fine at trigger
+    if !apu.pulse1.enabled {
+        t.Fatalf("Channel 1 should be playing")
+    }
+    apu.pulse1.clockSweep() // 0x780, and the next step (0xB40) overflows
+    if apu.pulse1.frequency != 0x780 || apu.pulse1.enabled {
+        t.Errorf("Sweep should update the frequency to 780 and then overflow, got %X enabled=%v", apu.pulse1.frequency, apu.pulse1.enabled)
+    }
+}
+
+func TestAPUNoiseLFSR(t *testing.T) {
+    apu := poweredAPU()
+    apu.write(0xFF21, 0xF0)
+    apu.write(0xFF22, 0x08) // 7-bit mode, divisor 8
+    apu.write(0xFF23, 0x80)
+    apu.noise.tick(8)
+    if apu.noise.lfsr != 0x3FBF {
+        t.Errorf("LFSR is %04X after one shift, expected 3FBF", apu.noise.lfsr)
+    }
+}
+
+func TestAPUSamples(t *testing.T) {
+    apu := poweredAPU()
+    apu.setSampleRate(44100)
+    apu.write(0xFF24, 0x77)
+    apu.write(0xFF25, 0x11) // Channel 1 on both sides
+    apu.write(0xFF11, 0x80) // 50% duty
+    apu.write(0xFF12, 0xF0)
+    apu.write(0xFF13, 0x00)
+    apu.write(0xFF14, 0x87) // ~1kHz
+
+    apu.update(CYCLESPERFRAME)
+    samples := apu.takeSamples()
+    if len(samples) < 738*2 || len(samples) > 740*2 {
+        t.Errorf("Expected ~739 stereo samples per frame, got %d values", len(samples))
+    }
+    low, high := 0, 0
+    for i := 0; i < len(samples); i += 2 {
+        if samples[i] != samples[i+1] {
+            t.Fatalf("Left and right should match when panned to the center")
+        }
+        if samples[i] < 0 {
+            low++
+        } else if samples[i] > 0 {
+            high++
+        }
+    }
+    if low == 0 || high == 0 {
+        t.Errorf("A square wave should produce both positive and negative samples")
+    }
+    if len(apu.takeSamples()) != 0 {
+        t.Errorf("Taking samples should empty the buffer")
+    }
+}
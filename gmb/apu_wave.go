@@ -0,0 +1,69 @@
+package gmb
+
+// WaveChannel - Channel 3 plays the 32 4-bit samples in wave RAM
+//
+//   NR30  E--- ----  DAC enable
+//   NR31  LLLL LLLL  Length load (256-L)
+//   NR32  -VV- ----  Volume: 0=mute, 1=100%, 2=50%, 3=25%
+//   NR33  FFFF FFFF  Frequency low bits
+//   NR34  TL-- -FFF  Trigger, length enable, frequency high bits
+type WaveChannel struct {
+    enabled bool
+    dacEnabled bool
+    volumeCode uint8
+    frequency int
+    timer int
+    position int
+    sample uint8 // The last sample which was read from wave RAM
+    length LengthCounter
+}
+
+// write - register is 0 for NR30 through 4 for NR34
+func (wave *WaveChannel) write(register uint16, data uint8) {
+    wave.length.maximum = 256
+    switch register {
+    case 0:
+        wave.dacEnabled = data & 0x80 != 0
+        if !wave.dacEnabled {
+            wave.enabled = false
+        }
+    case 1:
+        wave.length.load(int(data))
+    case 2:
+        wave.volumeCode = (data >> 5) & 0x3
+    case 3:
+        wave.frequency = (wave.frequency & 0x700) | int(data)
+    case 4:
+        wave.frequency = (wave.frequency & 0xFF) | int(data & 0x7) << 8
+        wave.length.enabled = data & 0x40 != 0
+        if data & 0x80 != 0 {
+            wave.enabled = wave.dacEnabled
+            wave.length.trigger()
+            wave.timer = (2048 - wave.frequency) * 2
+            wave.position = 0
+        }
+    }
+}
+
+// tick - Moves to the next sample every (2048-frequency)*2 cycles
+func (wave *WaveChannel) tick(cycles int, registers *[0x30]uint8) {
+    wave.timer -= cycles
+    for wave.timer <= 0 {
+        wave.timer += (2048 - wave.frequency) * 2
+        wave.position = (wave.position + 1) % 32
+        sampleByte := registers[0x20+wave.position/2]
+        if wave.position % 2 == 0 {
+            wave.sample = sampleByte >> 4
+        } else {
+            wave.sample = sampleByte & 0xF
+        }
+    }
+}
+
+// output - The digital output (0-15)
+func (wave *WaveChannel) output() uint8 {
+    if wave.volumeCode == 0 {
+        return 0
+    }
+    return wave.sample >> (wave.volumeCode - 1)
+}
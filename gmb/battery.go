@@ -0,0 +1,144 @@
+package gmb
+
+import (
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+// batteryBackedRAM - Implemented by memory bank controllers whose RAM can be kept alive by a battery
+type batteryBackedRAM interface {
+    saveData() []uint8 // Raw contents of the RAM (plus any extra footer) as stored in a .sav file
+    loadSaveData(data []uint8)
+}
+
+// hasBattery - Returns true if the cartridge type (0x147) declares a battery
+func hasBattery(cartridgeType uint8) bool {
+    switch cartridgeType {
+    case 0x03, 0x06, 0x09, 0x0D, 0x0F, 0x10, 0x13, 0x1B, 0x1E, 0xFF:
+        return true
+    }
+    return false
+}
+
+// SavePathForROM - Returns the path of the save file which belongs to the ROM: <rom>.sav
+// The extension of the ROM is replaced so that other emulators find the same file
+func SavePathForROM(romName string) string {
+    return strings.TrimSuffix(romName, filepath.Ext(romName)) + ".sav"
+}
+
+// batteryRAM - Returns the controller if the cartridge has battery-backed RAM, otherwise nil
+func (cart *Cartridge) batteryRAM() batteryBackedRAM {
+    if !hasBattery(cart.cartridgeType) {
+        return nil
+    }
+    ram, ok := cart.mbc.(batteryBackedRAM)
+    if !ok {
+        return nil
+    }
+    return ram
+}
+
+// loadSave - Loads the save file into the cartridge RAM. A missing save file is not an error;
+// the game simply starts with empty RAM
+func (cart *Cartridge) loadSave(path string) error {
+    ram := cart.batteryRAM()
+    if ram == nil {
+        return nil
+    }
+
+    cart.savePath = path
+    data, err := ioutil.ReadFile(path)
+    if os.IsNotExist(err) {
+        return nil
+    } else if err != nil {
+        return err
+    }
+    ram.loadSaveData(data)
+    return nil
+}
+
+// flushSave - Writes the cartridge RAM to the save file if anything was written since the last flush
+// The data is written to a temporary file first and then renamed over the old save file,
+// so a crash halfway through never leaves a corrupted save behind
+func (cart *Cartridge) flushSave() error {
+    ram := cart.batteryRAM()
+    if ram == nil || cart.savePath == "" || !cart.ramDirty {
+        return nil
+    }
+
+    tmpFile, err := ioutil.TempFile(filepath.Dir(cart.savePath), filepath.Base(cart.savePath)+".tmp")
+    if err != nil {
+        return err
+    }
+    defer os.Remove(tmpFile.Name()) // No-op once the rename succeeded
+
+    if _, err := tmpFile.Write(ram.saveData()); err != nil {
+        tmpFile.Close()
+        return err
+    }
+    if err := tmpFile.Sync(); err != nil {
+        tmpFile.Close()
+        return err
+    }
+    if err := tmpFile.Close(); err != nil {
+        return err
+    }
+    if err := os.Rename(tmpFile.Name(), cart.savePath); err != nil {
+        return err
+    }
+
+    cart.ramDirty = false
+    return nil
+}
+
+func (mbc *ROMOnly) saveData() []uint8 {
+    return append([]uint8(nil), mbc.ram...)
+}
+
+func (mbc *ROMOnly) loadSaveData(data []uint8) {
+    copy(mbc.ram, data)
+}
+
+func (mbc *MBC1) saveData() []uint8 {
+    return append([]uint8(nil), mbc.ram...)
+}
+
+func (mbc *MBC1) loadSaveData(data []uint8) {
+    copy(mbc.ram, data)
+}
+
+func (mbc *MBC2) saveData() []uint8 {
+    return append([]uint8(nil), mbc.ram[:]...)
+}
+
+func (mbc *MBC2) loadSaveData(data []uint8) {
+    for i := 0; i < len(data) && i < len(mbc.ram); i++ {
+        mbc.ram[i] = data[i] & 0x0F
+    }
+}
+
+// saveData - The RAM followed by the 48-byte RTC footer if the cartridge has a timer
+func (mbc *MBC3) saveData() []uint8 {
+    data := append([]uint8(nil), mbc.ram...)
+    if mbc.rtc != nil {
+        data = append(data, mbc.rtc.footer()...)
+    }
+    return data
+}
+
+func (mbc *MBC3) loadSaveData(data []uint8) {
+    copy(mbc.ram, data)
+    if mbc.rtc != nil && len(data) > len(mbc.ram) {
+        mbc.rtc.loadFooter(data[len(mbc.ram):])
+    }
+}
+
+func (mbc *MBC5) saveData() []uint8 {
+    return append([]uint8(nil), mbc.ram...)
+}
+
+func (mbc *MBC5) loadSaveData(data []uint8) {
+    copy(mbc.ram, data)
+}
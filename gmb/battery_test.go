@@ -0,0 +1,82 @@
+package gmb
+
+import (
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+)
+
+func TestSavePathForROM(t *testing.T) {
+    if SavePathForROM("roms/pokemon.gbc") != "roms/pokemon.sav" {
+        t.Errorf("Expected roms/pokemon.sav, got %s", SavePathForROM("roms/pokemon.gbc"))
+    }
+}
+
+func TestBatterySaveRoundTrip(t *testing.T) {
+    dir, err := ioutil.TempDir("", "gmb-save")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer os.RemoveAll(dir)
+    savePath := filepath.Join(dir, "game.sav")
+
+    cart := testCartridge(t, 0x03, 4, 0x02)
+    if err := cart.loadSave(savePath); err != nil {
+        t.Errorf("A missing save file should not be an error: %s", err)
+    }
+    cart.write8(0x0000, 0x0A)
+    cart.write8(0xA123, 0x42)
+    if err := cart.flushSave(); err != nil {
+        t.Fatal(err)
+    }
+
+    data, err := ioutil.ReadFile(savePath)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(data) != 8*1024 || data[0x123] != 0x42 {
+        t.Errorf("Save file should contain the raw 8KB of RAM")
+    }
+
+    restored := testCartridge(t, 0x03, 4, 0x02)
+    restored.loadSave(savePath)
+    restored.write8(0x0000, 0x0A)
+    if restored.read8(0xA123) != 0x42 {
+        t.Errorf("RAM was not restored from the save file")
+    }
+
+    files, _ := ioutil.ReadDir(dir)
+    if len(files) != 1 {
+        t.Errorf("Temporary files were left behind next to the save file")
+    }
+}
+
+func TestRTCFooter(t *testing.T) {
+    clock := &fakeClock{time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)}
+    mbc := newMBC3(testROM(0x10, 4, 0x03), 0x8000, true, clock.time)
+    mbc.write8(0x0000, 0x0A)
+    mbc.write8(0x4000, 0x09)
+    mbc.write8(0xA000, 30) // 30 minutes
+
+    data := mbc.saveData()
+    if len(data) != 0x8000+48 {
+        t.Fatalf("Expected the RAM followed by a 48-byte RTC footer, got %d bytes", len(data))
+    }
+
+    // Restore an hour later; the clock should have kept running
+    clock.now = clock.now.Add(time.Hour)
+    restored := newMBC3(testROM(0x10, 4, 0x03), 0x8000, true, clock.time)
+    restored.loadSaveData(data)
+    restored.write8(0x0000, 0x0A)
+    restored.write8(0x6000, 0x00)
+    restored.write8(0x6000, 0x01)
+    restored.write8(0x4000, 0x0A)
+    hours := restored.read8(0xA000)
+    restored.write8(0x4000, 0x09)
+    minutes := restored.read8(0xA000)
+    if hours != 1 || minutes != 30 {
+        t.Errorf("RTC: Expected 01:30 after restoring, got %02d:%02d", hours, minutes)
+    }
+}
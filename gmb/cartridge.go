@@ -0,0 +1,167 @@
+package gmb
+
+import (
+    "time"
+)
+
+// MemoryBankController - the bank switching hardware found on most cartridges.
+// It sees every access to the ROM area (0x0000-0x7FFF) and to the external RAM
+// area (0xA000-0xBFFF)
+type MemoryBankController interface {
+    read8(address uint16) uint8
+    write8(address uint16, data uint8)
+    currentROMBank() int // The bank currently mapped into 0x4000-0x7FFF
+    saveState() ([]byte, error) // The "CART" section of a save state (see savestate_cart.go)
+    loadState(data []byte) error
+}
+
+// Cartridge - exposes a read/write interface to a cartridge memory bank
+// based on the current settings
+// The cartridge only ever sees 0x0000-0x7FFF and 0xA000-0xBFFF
+type Cartridge struct {
+    rom []uint8 // The full ROM image as read from disk
+    header *CartridgeHeader // nil if the image is too small to have a header
+    cartridgeType uint8 // Header byte 0x147
+    mbc MemoryBankController
+
+    savePath string // Where battery-backed RAM is persisted. Empty if there is no battery
+    ramDirty bool // Set when external RAM was written since the last save
+}
+
+// Returns an 8-bit value at the given address
+func (cart *Cartridge) read8(address uint16) uint8 {
+    return cart.mbc.read8(address)
+}
+
+// Returns a 16-bit value starting from the given address
+// The value returned is formed by: <*address> | <*address+1> << 8
+func (cart *Cartridge) read16(address uint16) uint16 {
+    return uint16(cart.read8(address)) | (uint16(cart.read8(address+1)) << 8)
+}
+
+// Writes an 8-bit value to the 16-bit address provided.
+func (cart *Cartridge) write8(address uint16, data uint8) {
+    cart.mbc.write8(address, data)
+    if address >= 0xA000 {
+        cart.ramDirty = true
+    }
+}
+
+// Writes a 16-bit value to the 16-bit address provided
+// The low byte of data is stored at (address)
+// The high byte of data is stored at (address+1)
+func (cart *Cartridge) write16(address uint16, data uint16) {
+    cart.write8(address, uint8(data & 0xFF))
+    cart.write8(address+1, uint8(data >> 8))
+}
+
+// romBankCount - Number of 16KB banks in the ROM image
+func romBankCount(rom []uint8) int {
+    count := len(rom) / 0x4000
+    if count == 0 {
+        return 1
+    }
+    return count
+}
+
+// readROMBank - Reads a byte from the given 16KB ROM bank. Bank numbers larger than
+// the ROM wrap around, the same way the unconnected address lines do on hardware
+func readROMBank(rom []uint8, bank int, address uint16) uint8 {
+    bank %= romBankCount(rom)
+    offset := bank*0x4000 + int(address&0x3FFF)
+    if offset >= len(rom) {
+        return 0xFF
+    }
+    return rom[offset]
+}
+
+// newCartridge - Builds a cartridge around a ROM image and picks the memory bank
+// controller based on the cartridge type byte (0x147) in the header
+func newCartridge(rom []uint8) (*Cartridge, error) {
+    return newCartridgeWithClock(rom, time.Now)
+}
+
+// newCartridgeWithClock - newCartridge for cartridges whose real time clock should
+// get the time from somewhere else than the system clock
+func newCartridgeWithClock(rom []uint8, clock ClockSource) (*Cartridge, error) {
+    cart := new(Cartridge)
+    cart.rom = rom
+    ramSize := 0
+    if header, err := ParseHeader(rom); err == nil {
+        cart.header = header
+        cart.cartridgeType = header.CartridgeType
+        ramSize = header.RAMSize()
+    }
+
+    switch cart.cartridgeType {
+    case 0x01, 0x02, 0x03: // MBC1, MBC1+RAM, MBC1+RAM+BATTERY
+        cart.mbc = newMBC1(rom, ramSize)
+    case 0x05, 0x06: // MBC2, MBC2+BATTERY
+        cart.mbc = newMBC2(rom)
+    case 0x0F, 0x10: // MBC3+TIMER+BATTERY, MBC3+TIMER+RAM+BATTERY
+        cart.mbc = newMBC3(rom, ramSize, true, clock)
+    case 0x11, 0x12, 0x13: // MBC3, MBC3+RAM, MBC3+RAM+BATTERY
+        cart.mbc = newMBC3(rom, ramSize, false, clock)
+    case 0x19, 0x1A, 0x1B: // MBC5, MBC5+RAM, MBC5+RAM+BATTERY
+        cart.mbc = newMBC5(rom, ramSize, false)
+    case 0x1C, 0x1D, 0x1E: // MBC5+RUMBLE, MBC5+RUMBLE+RAM, MBC5+RUMBLE+RAM+BATTERY
+        cart.mbc = newMBC5(rom, ramSize, true)
+    case 0x00, 0x08, 0x09: // ROM ONLY, ROM+RAM, ROM+RAM+BATTERY
+        cart.mbc = newROMOnly(rom, ramSize)
+    default:
+        return nil, &ErrUnsupportedMapper{cart.cartridgeType}
+    }
+    return cart, nil
+}
+
+// ROMOnly - Cartridges without a memory bank controller: 32KB of ROM and optionally up to 8KB of RAM
+type ROMOnly struct {
+    rom [0x8000]uint8
+    ram []uint8
+}
+
+func (mbc *ROMOnly) read8(address uint16) uint8 {
+    if address < 0x8000 {
+        return mbc.rom[address]
+    }
+    offset := int(address - 0xA000)
+    if offset >= len(mbc.ram) {
+        return 0xFF // Open bus
+    }
+    return mbc.ram[offset]
+}
+
+func (mbc *ROMOnly) currentROMBank() int {
+    return 1
+}
+
+// Writes to the ROM area go straight through to the ROM image
+// TODO: Check to make sure that data is being written to RAM and not ROM
+func (mbc *ROMOnly) write8(address uint16, data uint8) {
+    if address < 0x8000 {
+        mbc.rom[address] = data
+        return
+    }
+    offset := int(address - 0xA000)
+    if offset < len(mbc.ram) {
+        mbc.ram[offset] = data
+    }
+}
+
+func newROMOnly(rom []uint8, ramSize int) *ROMOnly {
+    mbc := new(ROMOnly)
+    copy(mbc.rom[:], rom)
+    if ramSize > 0x2000 {
+        ramSize = 0x2000
+    }
+    mbc.ram = make([]uint8, ramSize)
+    return mbc
+}
+
+// setRumbleCallback - Registers a function which is called whenever the rumble motor
+// switches on or off. Has no effect on cartridges without a rumble motor
+func (cart *Cartridge) setRumbleCallback(callback RumbleCallback) {
+    if mbc, ok := cart.mbc.(*MBC5); ok {
+        mbc.rumble = callback
+    }
+}
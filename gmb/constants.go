@@ -0,0 +1,23 @@
+package gmb
+
+// SCREENWIDTH - Horizontal resolution of the display (columns of pixels)
+//var SCREENWIDTH = 256
+
+// SCREENHEIGHT - Vertical Resolution of the display (rows of pixels)
+//var SCREENHEIGHT = 256
+
+// LCDWIDTH - The width of the display (in pixels)
+var LCDWIDTH = uint8(160)
+
+// LCDHEIGHT - The hight of the display (in pixels)
+var LCDHEIGHT = uint8(144)
+
+
+// CYCLESPERFRAME - How many cycles to run every frame (59.73 frames per second).
+// Ebiten ticks at 60fps, so the frontend paces itself off the audio buffer instead
+// of running one frame per tick, which would be 0.5% too fast
+var CYCLESPERFRAME = 70224
+
+// GameBoyColorMap - The default output palette; the RGBA colors of shades 0-3 after BGP/OBP0/OBP1
+// have been applied. Others can be picked with WithPalette (see palette.go)
+var GameBoyColorMap = Palette{ 0xFFFFFFFF, 0xB6B6B6FF, 0x676767FF, 0x000000FF}
\ No newline at end of file
@@ -0,0 +1,52 @@
+package gmb
+
+import (
+    "fmt"
+)
+
+func debugPrintHeader(cpu *CPU) {
+    if cpu.instructionsExecuted%20 == 0 {
+
+        fmt.Printf("ADDR : %-27sB  C  D  E  H  L  A  ZNHC---- SP\n","instruction")
+    }
+}
+
+// debugPrint - will output a single line to the console regarding the current instruction
+// Only prints if the CPU is in debug mode
+// Format:
+// INST : PC <values> <instruction name> RB RC RD RE RH RL RA PSW SP
+func debugPrint(cpu *CPU, name string, values int) {
+    if !cpu.debugMode {
+        return
+    }
+
+    debugPrintHeader(cpu)
+
+    output := ""
+
+    // Hard-wire an 0xCB before printing extended mode instructions
+    cmd := ""
+    if cpu.mmu.peek(cpu.programCounter-1) != 0xCB {
+        cmd = fmt.Sprintf("%04X : %02X", cpu.programCounter, cpu.mmu.peek(cpu.programCounter))
+    } else {
+        cmd = fmt.Sprintf("%04X : CB %02X", cpu.programCounter-1, cpu.mmu.peek(cpu.programCounter))
+    }
+
+    for i := 1; i < values; i++ {
+        cmd += fmt.Sprintf(" %02X", cpu.mmu.peek(cpu.programCounter+uint16(i)))
+    }
+    output += fmt.Sprintf("%-17s %-16s", cmd, name)
+
+    //                      rb  rc   rd   re   rh   rl   ra   psw  SP
+    output += fmt.Sprintf("%02X %02X %02X %02X %02X %02X %02X %08b %04X %02X %v\n",
+        cpu.rb, cpu.rc, cpu.rd, cpu.re, cpu.rh, cpu.rl, cpu.ra, cpu.pswByte(), cpu.stackPointer, cpu.mmu.getTIMA(), cpu.timer.cpuCycles)
+
+    fmt.Print(output)
+}
+
+// stateString - One line summary of the CPU registers
+func (cpu *CPU) stateString() string {
+    return fmt.Sprintf("PC=%04X SP=%04X A=%02X F=%08b B=%02X C=%02X D=%02X E=%02X H=%02X L=%02X IME=%t after %d instructions",
+        cpu.programCounter, cpu.stackPointer, cpu.ra, cpu.pswByte(), cpu.rb, cpu.rc, cpu.rd, cpu.re, cpu.rh, cpu.rl,
+        cpu.inte, cpu.instructionsExecuted)
+}
@@ -0,0 +1,450 @@
+package gmb
+
+import (
+    "fmt"
+    "io"
+    "strings"
+    "sync/atomic"
+)
+
+// Debugger - Runs a GameBoy instruction by instruction and stops at breakpoints.
+// It is the engine behind the command line debugger; all run methods return why they stopped
+type Debugger struct {
+    gb *GameBoy
+    breakpoints []*Breakpoint // In the order they were added
+    breakpointsAt map[uint16][]*Breakpoint // Index of the breakpoints with an address
+    anyAddressBreakpoints []*Breakpoint
+    nextBreakpointID int
+    breakpointHits []Breakpoint // What stopped the last run with StopBreakpoint
+    traceOutput io.Writer // Where tracepoints log to. May be nil
+    watchpoints []Watchpoint
+    nextWatchpointID int
+    watchpointHits []WatchpointHit // What stopped the last run with StopWatchpoint
+    interrupted int32 // Set from another goroutine by Interrupt
+}
+
+// StopReason - Why one of the run methods of the Debugger returned
+type StopReason int
+
+// The reasons for stopping
+const (
+    StopStep StopReason = iota // The requested step finished
+    StopBreakpoint             // A breakpoint matched (see BreakpointHits)
+    StopWatchpoint             // The last instruction accessed watched memory (see WatchpointHits)
+    StopInterrupted            // Interrupt was called
+    StopError                  // The CPU could not continue (see the returned error)
+)
+
+func (reason StopReason) String() string {
+    return [...]string{"step", "breakpoint", "watchpoint", "interrupted", "error"}[reason]
+}
+
+// Registers - A copy of the CPU registers
+type Registers struct {
+    A, F, B, C, D, E, H, L uint8
+    SP, PC uint16
+    IME bool
+    Halted bool
+}
+
+// Breakpoint - Stops the run methods before an instruction is executed
+type Breakpoint struct {
+    ID int // Assigned by Debugger.AddBreakpoint
+    Address uint16
+    AnyAddress bool // Ignore Address and check the condition before every instruction (slow)
+    Condition *Expression // The breakpoint only matches when this is not 0. nil always matches
+    IgnoreCount int // The first IgnoreCount matches are only counted
+    Trace bool // Log the match to the trace output and keep running instead of stopping
+    Hits int // How often the breakpoint matched so far
+}
+
+// String - e.g. "2A10 if a == 5 (trace, ignore $3, 4 hits)"
+func (breakpoint Breakpoint) String() string {
+    text := fmt.Sprintf("%04X", breakpoint.Address)
+    if breakpoint.AnyAddress {
+        text = "anywhere"
+    }
+    if breakpoint.Condition != nil {
+        text += " if " + breakpoint.Condition.String()
+    }
+    var details []string
+    if breakpoint.Trace {
+        details = append(details, "trace")
+    }
+    if breakpoint.IgnoreCount > 0 {
+        details = append(details, fmt.Sprintf("ignore $%X", breakpoint.IgnoreCount))
+    }
+    if breakpoint.Hits == 1 {
+        details = append(details, "1 hit")
+    } else {
+        details = append(details, fmt.Sprintf("%d hits", breakpoint.Hits))
+    }
+    return text + " (" + strings.Join(details, ", ") + ")"
+}
+
+// NewDebugger - Creates a debugger for the machine. The machine should only be run
+// through the debugger while it is being debugged
+func NewDebugger(gb *GameBoy) *Debugger {
+    return &Debugger{gb: gb, breakpointsAt: make(map[uint16][]*Breakpoint), nextBreakpointID: 1, nextWatchpointID: 1}
+}
+
+// SetBreakpoint - Adds a plain breakpoint at the address and returns its ID
+func (debugger *Debugger) SetBreakpoint(address uint16) int {
+    return debugger.AddBreakpoint(Breakpoint{Address: address})
+}
+
+// AddBreakpoint - Adds the breakpoint and returns its ID
+func (debugger *Debugger) AddBreakpoint(breakpoint Breakpoint) int {
+    breakpoint.ID = debugger.nextBreakpointID
+    debugger.nextBreakpointID++
+    debugger.breakpoints = append(debugger.breakpoints, &breakpoint)
+    debugger.indexBreakpoints()
+    return breakpoint.ID
+}
+
+// RemoveBreakpoint - Returns false if there is no breakpoint with the ID
+func (debugger *Debugger) RemoveBreakpoint(id int) bool {
+    for i, breakpoint := range debugger.breakpoints {
+        if breakpoint.ID == id {
+            debugger.breakpoints = append(debugger.breakpoints[:i], debugger.breakpoints[i+1:]...)
+            debugger.indexBreakpoints()
+            return true
+        }
+    }
+    return false
+}
+
+// ClearBreakpoint - Removes every breakpoint at the address. Returns false if there were none
+func (debugger *Debugger) ClearBreakpoint(address uint16) bool {
+    kept := debugger.breakpoints[:0]
+    for _, breakpoint := range debugger.breakpoints {
+        if breakpoint.AnyAddress || breakpoint.Address != address {
+            kept = append(kept, breakpoint)
+        }
+    }
+    removed := len(kept) != len(debugger.breakpoints)
+    debugger.breakpoints = kept
+    debugger.indexBreakpoints()
+    return removed
+}
+
+// indexBreakpoints - Rebuilds the lookups used while running
+func (debugger *Debugger) indexBreakpoints() {
+    debugger.breakpointsAt = make(map[uint16][]*Breakpoint)
+    debugger.anyAddressBreakpoints = nil
+    for _, breakpoint := range debugger.breakpoints {
+        if breakpoint.AnyAddress {
+            debugger.anyAddressBreakpoints = append(debugger.anyAddressBreakpoints, breakpoint)
+        } else {
+            debugger.breakpointsAt[breakpoint.Address] = append(debugger.breakpointsAt[breakpoint.Address], breakpoint)
+        }
+    }
+}
+
+// Breakpoints - Copies of the breakpoints in the order they were added
+func (debugger *Debugger) Breakpoints() []Breakpoint {
+    breakpoints := make([]Breakpoint, len(debugger.breakpoints))
+    for i, breakpoint := range debugger.breakpoints {
+        breakpoints[i] = *breakpoint
+    }
+    return breakpoints
+}
+
+// BreakpointHits - The breakpoints which made the last run stop with StopBreakpoint
+func (debugger *Debugger) BreakpointHits() []Breakpoint {
+    return debugger.breakpointHits
+}
+
+// SetTraceOutput - Where tracepoints log their matches
+func (debugger *Debugger) SetTraceOutput(output io.Writer) {
+    debugger.traceOutput = output
+}
+
+// Evaluate - Parses and evaluates an expression against the current state of the machine
+func (debugger *Debugger) Evaluate(text string) (int64, error) {
+    expression, err := ParseExpression(text)
+    if err != nil {
+        return 0, err
+    }
+    return expression.evaluate(debugger.gb.cpu), nil
+}
+
+// checkBreakpoints - Counts the matching breakpoints at the current PC and logs the tracepoints.
+// Returns true if one of them should stop the run
+func (debugger *Debugger) checkBreakpoints() bool {
+    cpu := debugger.gb.cpu
+    candidates := debugger.breakpointsAt[cpu.programCounter]
+    if len(candidates) == 0 && len(debugger.anyAddressBreakpoints) == 0 {
+        return false
+    }
+    debugger.breakpointHits = debugger.breakpointHits[:0]
+    for _, list := range [][]*Breakpoint{candidates, debugger.anyAddressBreakpoints} {
+        for _, breakpoint := range list {
+            if breakpoint.Condition != nil && breakpoint.Condition.evaluate(cpu) == 0 {
+                continue
+            }
+            breakpoint.Hits++
+            if breakpoint.Hits <= breakpoint.IgnoreCount {
+                continue
+            }
+            if breakpoint.Trace {
+                if debugger.traceOutput != nil {
+                    fmt.Fprintf(debugger.traceOutput, "Trace %d (hit %d): %s\n",
+                        breakpoint.ID, breakpoint.Hits, cpu.stateString())
+                }
+                continue
+            }
+            debugger.breakpointHits = append(debugger.breakpointHits, *breakpoint)
+        }
+    }
+    return len(debugger.breakpointHits) > 0
+}
+
+// AddWatchpoint - Installs the watchpoint and returns its ID
+func (debugger *Debugger) AddWatchpoint(watchpoint Watchpoint) int {
+    watchpoint.ID = debugger.nextWatchpointID
+    debugger.nextWatchpointID++
+    debugger.watchpoints = append(debugger.watchpoints, watchpoint)
+    return watchpoint.ID
+}
+
+// RemoveWatchpoint - Returns false if there is no watchpoint with the ID
+func (debugger *Debugger) RemoveWatchpoint(id int) bool {
+    for i, watchpoint := range debugger.watchpoints {
+        if watchpoint.ID == id {
+            debugger.watchpoints = append(debugger.watchpoints[:i], debugger.watchpoints[i+1:]...)
+            if len(debugger.watchpoints) == 0 {
+                debugger.watchpoints = nil // Takes the MMU back to the unwatched path
+            }
+            return true
+        }
+    }
+    return false
+}
+
+// Watchpoints - The installed watchpoints in the order they were added
+func (debugger *Debugger) Watchpoints() []Watchpoint {
+    return append([]Watchpoint(nil), debugger.watchpoints...)
+}
+
+// WatchpointHits - The accesses which made the last run stop with StopWatchpoint
+func (debugger *Debugger) WatchpointHits() []WatchpointHit {
+    return debugger.watchpointHits
+}
+
+// Interrupt - Makes a running Continue (or other run method) stop at the next instruction.
+// An interrupt which arrives while nothing is running stops the next run straight away.
+// Safe to call from another goroutine, e.g. a Ctrl+C handler
+func (debugger *Debugger) Interrupt() {
+    atomic.StoreInt32(&debugger.interrupted, 1)
+}
+
+// ResetInterrupt - Forgets an Interrupt which has not stopped a run yet. Call it before
+// resuming, not after, so that an interrupt sent right after the resume is never lost
+func (debugger *Debugger) ResetInterrupt() {
+    atomic.StoreInt32(&debugger.interrupted, 0)
+}
+
+// Step - Executes a single instruction (and dispatches any interrupt that follows it).
+// Returns StopWatchpoint if it accessed watched memory
+func (debugger *Debugger) Step() (StopReason, error) {
+    mmu := debugger.gb.cpu.mmu
+    mmu.watchpoints = debugger.watchpoints // The machine may have been reset since the last step
+    mmu.watchpointHits = mmu.watchpointHits[:0]
+    pc := debugger.gb.cpu.programCounter
+    if _, err := debugger.gb.Step(); err != nil {
+        return StopError, err
+    }
+
+    if len(mmu.watchpointHits) == 0 {
+        return StopStep, nil
+    }
+    debugger.watchpointHits = make([]WatchpointHit, len(mmu.watchpointHits))
+    for i, hit := range mmu.watchpointHits {
+        hit.PC = pc
+        debugger.watchpointHits[i] = hit
+    }
+    return StopWatchpoint, nil
+}
+
+// run - Steps until done returns true, a breakpoint is reached or something goes wrong.
+// done is given the opcode of the instruction that was just executed.
+// The instruction at the starting PC is always executed, so continuing from a breakpoint works
+func (debugger *Debugger) run(done func(opcode uint8) bool) (StopReason, error) {
+    cpu := debugger.gb.cpu
+    for {
+        opcode := cpu.mmu.peek(cpu.programCounter)
+        if reason, err := debugger.Step(); err != nil || reason == StopWatchpoint {
+            return reason, err
+        }
+        if debugger.checkBreakpoints() { // Before done so that landing on a breakpoint is reported as one
+            return StopBreakpoint, nil
+        }
+        if done(opcode) {
+            return StopStep, nil
+        }
+        if atomic.CompareAndSwapInt32(&debugger.interrupted, 1, 0) { // Each interrupt stops one run
+            return StopInterrupted, nil
+        }
+    }
+}
+
+// Continue - Runs until a breakpoint is reached or the debugger is interrupted
+func (debugger *Debugger) Continue() (StopReason, error) {
+    return debugger.run(func(opcode uint8) bool { return false })
+}
+
+// RunTo - Runs until PC reaches the address (or a breakpoint on the way)
+func (debugger *Debugger) RunTo(address uint16) (StopReason, error) {
+    cpu := debugger.gb.cpu
+    return debugger.run(func(opcode uint8) bool { return cpu.programCounter == address })
+}
+
+// StepOver - Like Step, but a CALL or RST runs until it returns to the next instruction
+func (debugger *Debugger) StepOver() (StopReason, error) {
+    cpu := debugger.gb.cpu
+    if !isCallOpcode(cpu.mmu.peek(cpu.programCounter)) {
+        return debugger.Step()
+    }
+    returnAddress := cpu.programCounter + cpu.instructionLength(cpu.programCounter)
+    stackPointer := cpu.stackPointer
+    return debugger.run(func(opcode uint8) bool {
+        // The stack check keeps recursive calls from stopping early. It allows the stack to wrap around
+        return cpu.programCounter == returnAddress && int16(cpu.stackPointer - stackPointer) >= 0
+    })
+}
+
+// StepOut - Runs until the current function returns to its caller
+func (debugger *Debugger) StepOut() (StopReason, error) {
+    cpu := debugger.gb.cpu
+    stackPointer := cpu.stackPointer
+    return debugger.run(func(opcode uint8) bool {
+        // Returns from nested calls (and interrupt handlers) do not go above the starting stack
+        return isReturnOpcode(opcode) && int16(cpu.stackPointer - stackPointer) > 0
+    })
+}
+
+// isCallOpcode - CALL, CALL cc and RST push a return address
+func isCallOpcode(opcode uint8) bool {
+    return opcode == 0xCD || opcode & 0xE7 == 0xC4 || opcode & 0xC7 == 0xC7
+}
+
+// isReturnOpcode - RET, RETI and RET cc
+func isReturnOpcode(opcode uint8) bool {
+    return opcode == 0xC9 || opcode == 0xD9 || opcode & 0xE7 == 0xC0
+}
+
+// Registers - Returns a copy of the CPU registers
+func (debugger *Debugger) Registers() Registers {
+    cpu := debugger.gb.cpu
+    return Registers{
+        cpu.ra, cpu.pswByte(), cpu.rb, cpu.rc, cpu.rd, cpu.re, cpu.rh, cpu.rl,
+        cpu.stackPointer, cpu.programCounter, cpu.inte, cpu.halted,
+    }
+}
+
+// SetRegisters - Overwrites the CPU registers. The low 4 bits of F do not exist and are ignored
+func (debugger *Debugger) SetRegisters(registers Registers) {
+    cpu := debugger.gb.cpu
+    cpu.ra, cpu.rb, cpu.rc, cpu.rd, cpu.re, cpu.rh, cpu.rl =
+        registers.A, registers.B, registers.C, registers.D, registers.E, registers.H, registers.L
+    cpu.zero = registers.F & 0x80 != 0
+    cpu.subtract = registers.F & 0x40 != 0
+    cpu.halfCarry = registers.F & 0x20 != 0
+    cpu.carry = registers.F & 0x10 != 0
+    cpu.stackPointer = registers.SP
+    cpu.programCounter = registers.PC
+    cpu.inte = registers.IME
+    cpu.halted = registers.Halted
+}
+
+// String - Registers and flags on two lines
+func (registers Registers) String() string {
+    flags := ""
+    for i, name := range []string{"Z", "N", "H", "C"} {
+        if registers.F & (0x80 >> uint(i)) != 0 {
+            flags += name
+        } else {
+            flags += "-"
+        }
+    }
+    return fmt.Sprintf("A=%02X F=%02X B=%02X C=%02X D=%02X E=%02X H=%02X L=%02X\n"+
+        "SP=%04X PC=%04X flags=%s IME=%t halted=%t",
+        registers.A, registers.F, registers.B, registers.C, registers.D, registers.E, registers.H, registers.L,
+        registers.SP, registers.PC, flags, registers.IME, registers.Halted)
+}
+
+// ReadMemory - Reads memory without side effects (DMA and watchpoints do not apply)
+func (debugger *Debugger) ReadMemory(address uint16, length int) []uint8 {
+    data := make([]uint8, length)
+    for i := range data {
+        data[i] = debugger.gb.cpu.mmu.peek(address + uint16(i))
+    }
+    return data
+}
+
+// WriteMemory - Writes memory as the CPU would, except that DMA does not block it.
+// Writes to 0x0000-0x7FFF go to the memory bank controller, not to the ROM
+func (debugger *Debugger) WriteMemory(address uint16, data []uint8) {
+    for i, value := range data {
+        debugger.gb.cpu.mmu.poke(address + uint16(i), value)
+    }
+}
+
+// Disassemble - Decodes count instructions starting at address
+func (debugger *Debugger) Disassemble(address uint16, count int) []DisassembledInstruction {
+    instructions := make([]DisassembledInstruction, 0, count)
+    for i := 0; i < count; i++ {
+        instruction := debugger.gb.cpu.disassemble(address)
+        instructions = append(instructions, instruction)
+        address += uint16(len(instruction.Bytes))
+    }
+    return instructions
+}
+
+// DisassembleAround - Up to before instructions leading up to address, the instruction at
+// address and after more. Instructions have different lengths, so decoding starts from the
+// furthest point back which lands on an instruction boundary at address
+func (debugger *Debugger) DisassembleAround(address uint16, before int, after int) []DisassembledInstruction {
+    for back := uint16(before * 3); back > 0; back-- {
+        start := address - back
+        var leading []DisassembledInstruction
+        for current := start; current - start < back; {
+            instruction := debugger.gb.cpu.disassemble(current)
+            leading = append(leading, instruction)
+            current += uint16(len(instruction.Bytes))
+        }
+        end := leading[len(leading)-1]
+        if end.Address + uint16(len(end.Bytes)) - start == back {
+            if len(leading) > before {
+                leading = leading[len(leading)-before:]
+            }
+            return append(leading, debugger.Disassemble(address, after+1)...)
+        }
+    }
+    return debugger.Disassemble(address, after+1)
+}
+
+// HexDump - Formats memory like "C000: 00 01 02 ... 0F  ................"
+func (debugger *Debugger) HexDump(address uint16, length int) string {
+    var lines []string
+    data := debugger.ReadMemory(address, length)
+    for offset := 0; offset < len(data); offset += 16 {
+        end := offset + 16
+        if end > len(data) {
+            end = len(data)
+        }
+        hex := ""
+        text := ""
+        for _, b := range data[offset:end] {
+            hex += fmt.Sprintf("%02X ", b)
+            if b >= 0x20 && b < 0x7F {
+                text += string(rune(b))
+            } else {
+                text += "."
+            }
+        }
+        lines = append(lines, fmt.Sprintf("%04X: %-48s %s", address + uint16(offset), hex, text))
+    }
+    return strings.Join(lines, "\n")
+}
@@ -0,0 +1,141 @@
+package gmb
+
+import (
+    "testing"
+)
+
+// debuggerMachine - A machine running a small program:
+//   0100: CALL $0200 / 0103: JR $0103
+//   0200: LD A,$05 / 0202: CALL $0300 / 0205: RET
+//   0300: INC A / 0301: RET
+func debuggerMachine(t *testing.T) *Debugger {
+    gb, err := New(debuggerMachineROM())
+    if err != nil {
+        t.Fatal(err)
+    }
+    return NewDebugger(gb)
+}
+
+func debuggerMachineROM() []uint8 {
+    rom := validROM(0x00, 2, 0x00)
+    copy(rom[0x100:], []uint8{0xCD, 0x00, 0x02, 0x18, 0xFE})
+    copy(rom[0x200:], []uint8{0x3E, 0x05, 0xCD, 0x00, 0x03, 0xC9})
+    copy(rom[0x300:], []uint8{0x3C, 0xC9})
+    return rom
+}
+
+func expectStop(t *testing.T, debugger *Debugger, reason StopReason, err error, expectedReason StopReason, pc uint16) {
+    t.Helper()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if reason != expectedReason || debugger.Registers().PC != pc {
+        t.Errorf("Expected to stop (%s) at %04X, stopped (%s) at %04X", expectedReason, pc, reason, debugger.Registers().PC)
+    }
+}
+
+func TestDebuggerBreakpoints(t *testing.T) {
+    debugger := debuggerMachine(t)
+    debugger.SetBreakpoint(0x0300)
+    debugger.SetBreakpoint(0x0205)
+    reason, err := debugger.Continue()
+    expectStop(t, debugger, reason, err, StopBreakpoint, 0x0300)
+    reason, err = debugger.Continue()
+    expectStop(t, debugger, reason, err, StopBreakpoint, 0x0205)
+    if debugger.Registers().A != 0x06 {
+        t.Errorf("A should be 06 after INC A, got %02X", debugger.Registers().A)
+    }
+
+    if !debugger.ClearBreakpoint(0x0300) || debugger.ClearBreakpoint(0x0300) {
+        t.Errorf("ClearBreakpoint should only report existing breakpoints")
+    }
+    if breakpoints := debugger.Breakpoints(); len(breakpoints) != 1 || breakpoints[0].Address != 0x0205 {
+        t.Errorf("Unexpected breakpoints %v", breakpoints)
+    }
+
+    reason, err = debugger.RunTo(0x0103)
+    expectStop(t, debugger, reason, err, StopStep, 0x0103)
+
+    // Running to an address with a breakpoint reports the breakpoint
+    debugger = debuggerMachine(t)
+    id := debugger.SetBreakpoint(0x0300)
+    reason, err = debugger.RunTo(0x0300)
+    expectStop(t, debugger, reason, err, StopBreakpoint, 0x0300)
+    if hits := debugger.BreakpointHits(); len(hits) != 1 || hits[0].ID != id {
+        t.Errorf("Unexpected breakpoint hits %v", hits)
+    }
+}
+
+func TestDebuggerStepOverAndOut(t *testing.T) {
+    debugger := debuggerMachine(t)
+    reason, err := debugger.StepOver()
+    expectStop(t, debugger, reason, err, StopStep, 0x0103)
+    if debugger.Registers().A != 0x06 {
+        t.Errorf("The call should have run to completion")
+    }
+
+    debugger = debuggerMachine(t)
+    debugger.RunTo(0x0300)
+    reason, err = debugger.StepOut()
+    expectStop(t, debugger, reason, err, StopStep, 0x0205)
+    reason, err = debugger.StepOut()
+    expectStop(t, debugger, reason, err, StopStep, 0x0103)
+
+    // A breakpoint inside the call stops a step over early
+    debugger = debuggerMachine(t)
+    debugger.SetBreakpoint(0x0300)
+    reason, err = debugger.StepOver()
+    expectStop(t, debugger, reason, err, StopBreakpoint, 0x0300)
+}
+
+func TestDebuggerRegistersAndMemory(t *testing.T) {
+    debugger := debuggerMachine(t)
+    registers := debugger.Registers()
+    registers.F = 0xAF
+    registers.H, registers.L = 0xC0, 0x10
+    debugger.SetRegisters(registers)
+    if got := debugger.Registers(); got.F != 0xA0 || got.H != 0xC0 || got.L != 0x10 {
+        t.Errorf("Registers were not written: %s", got)
+    }
+
+    debugger.WriteMemory(0xC010, []uint8{1, 2, 3})
+    if data := debugger.ReadMemory(0xC00F, 5); data[0] != 0 || data[1] != 1 || data[3] != 3 || data[4] != 0 {
+        t.Errorf("Unexpected memory %v", data)
+    }
+}
+
+func TestDisassemble(t *testing.T) {
+    debugger := debuggerMachine(t)
+    instructions := debugger.Disassemble(0x0200, 3)
+    expected := []string{"0200: 3E 05     LD A, d8 $05", "0202: CD 00 03  CALL $0300", "0205: C9        RET"}
+    for i, instruction := range instructions {
+        if instruction.String() != expected[i] {
+            t.Errorf("Expected %q, got %q", expected[i], instruction.String())
+        }
+    }
+    if text := debugger.Disassemble(0x0103, 1)[0].Text; text != "JR $0103" {
+        t.Errorf("Relative jumps should show the target, got %q", text)
+    }
+
+    around := debugger.DisassembleAround(0x0205, 2, 1)
+    if len(around) != 4 || around[0].Address != 0x0200 || around[2].Address != 0x0205 {
+        t.Errorf("Unexpected instructions around 0205: %v", around)
+    }
+}
+
+func TestDebuggerInterrupt(t *testing.T) {
+    // An interrupt sent just after resuming must not be lost, so run does not clear it
+    debugger := debuggerMachine(t)
+    debugger.Interrupt()
+    reason, err := debugger.Continue()
+    expectStop(t, debugger, reason, err, StopInterrupted, 0x0200)
+
+    // It only stops one run, and ResetInterrupt forgets one which has not stopped anything yet
+    debugger.SetBreakpoint(0x0300)
+    reason, err = debugger.Continue()
+    expectStop(t, debugger, reason, err, StopBreakpoint, 0x0300)
+    debugger.Interrupt()
+    debugger.ResetInterrupt()
+    reason, err = debugger.RunTo(0x0103)
+    expectStop(t, debugger, reason, err, StopStep, 0x0103)
+}
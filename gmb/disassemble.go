@@ -0,0 +1,58 @@
+package gmb
+
+import (
+    "fmt"
+    "strings"
+)
+
+// DisassembledInstruction - One decoded instruction
+type DisassembledInstruction struct {
+    Address uint16
+    Bytes []uint8
+    Text string // The name from the instruction table plus the operand, e.g. "CALL $4A12"
+}
+
+// String - "0150: CD 12 4A  CALL $4A12"
+func (inst DisassembledInstruction) String() string {
+    bytes := make([]string, len(inst.Bytes))
+    for i, b := range inst.Bytes {
+        bytes[i] = fmt.Sprintf("%02X", b)
+    }
+    return fmt.Sprintf("%04X: %-9s %s", inst.Address, strings.Join(bytes, " "), inst.Text)
+}
+
+// disassemble - Decodes the instruction at address using the names in the instruction tables.
+// Memory is read without side effects
+func (cpu *CPU) disassemble(address uint16) DisassembledInstruction {
+    opcode := cpu.mmu.peek(address)
+    if opcode == 0xCB {
+        extended := cpu.mmu.peek(address + 1)
+        return DisassembledInstruction{address, []uint8{opcode, extended}, cpu.extendedInstructions[extended].name}
+    }
+
+    info := cpu.mainInstructions[opcode]
+    length := info.dataSize
+    if length < 1 {
+        length = 1 // Illegal opcodes
+    }
+    bytes := make([]uint8, length)
+    for i := range bytes {
+        bytes[i] = cpu.mmu.peek(address + uint16(i))
+    }
+
+    text := info.name
+    switch {
+    case length == 2 && (opcode == 0x18 || opcode & 0xE7 == 0x20): // JR, JR cc
+        text += fmt.Sprintf(" $%04X", uint16(int(address) + 2 + int(int8(bytes[1]))))
+    case length == 2:
+        text += fmt.Sprintf(" $%02X", bytes[1])
+    case length == 3:
+        text += fmt.Sprintf(" $%04X", uint16(bytes[2])<<8 | uint16(bytes[1]))
+    }
+    return DisassembledInstruction{address, bytes, text}
+}
+
+// instructionLength - Number of bytes taken by the instruction at address
+func (cpu *CPU) instructionLength(address uint16) uint16 {
+    return uint16(len(cpu.disassemble(address).Bytes))
+}
@@ -0,0 +1,214 @@
+package gmb
+
+import ( 
+    "image"
+)
+
+// Display - represents the LCD of the game boy
+type Display struct {
+    cpu * CPU
+    scanlineCounter int
+    internalImage *image.RGBA
+    renderingEnabled bool // When false the LCD timing still runs, but no pixels are drawn
+    windowLine uint8 // Internal window line counter; only advances on lines where the window was drawn
+    palette Palette // The RGBA colors of the four shades
+}
+
+// (0,0)               (0,255)
+//      +----------------+
+//      |    (scrX,Scry) |
+//      |--+      +------|
+//      |  |      |      |
+//      |--+      +------|
+//      |                |
+//      +----------------+
+//
+// Returns the color numbers of the line which are needed to resolve sprite priority
+func (display * Display) drawBackgroundLine(ly uint8) [160]uint8 {
+    var colorNumbers [160]uint8
+    tileY := ly + display.cpu.mmu.scrollY() // This will overflow as needed!
+    enabled := display.cpu.mmu.backgroundEnabled()
+   
+    for lcdX := uint8(0); lcdX < LCDWIDTH; lcdX++ {
+        if !enabled { // A disabled background is white whatever BGP says, but still color 0 for sprite priority
+            display.setPixel(lcdX, ly, display.palette[0])
+            continue
+        }
+        tileX := lcdX + display.cpu.mmu.scrollX() // This will overflow as needed
+        colorNumbers[lcdX] = display.cpu.mmu.backgroundColorNumberAt(tileX,tileY)
+        display.setPixel(lcdX, ly, display.palette[shade(display.cpu.mmu.backgroundPalette(), colorNumbers[lcdX])])
+    }
+    return colorNumbers
+}
+
+// drawWindowLine - Draws the window over the background. The window starts at
+// screen column WX-7 and row WY, and is always drawn from its own top-left corner
+// using the internal line counter, so hiding it for a few lines does not skip window rows
+func (display * Display) drawWindowLine(ly uint8, colorNumbers *[160]uint8) {
+    mmu := display.cpu.mmu
+    if !mmu.windowEnabled() || !mmu.backgroundEnabled() || ly < mmu.windowY() {
+        return
+    }
+    left := int(mmu.windowX()) - 7
+    if left >= int(LCDWIDTH) {
+        return // WX > 166 puts the window off the screen
+    }
+
+    for lcdX := 0; lcdX < int(LCDWIDTH); lcdX++ {
+        if lcdX < left {
+            continue
+        }
+        colorNumbers[lcdX] = mmu.windowColorNumberAt(uint8(lcdX - left), display.windowLine)
+        display.setPixel(uint8(lcdX), ly, display.palette[shade(mmu.backgroundPalette(), colorNumbers[lcdX])])
+    }
+    display.windowLine++
+}
+
+// drawSpriteLine - Draws the sprites which overlap the line on top of the background.
+// Sprites are visited from highest to lowest priority; the first opaque pixel claims
+// the column even when the background ends up being drawn over it
+func (display * Display) drawSpriteLine(ly uint8, backgroundColors [160]uint8) {
+    mmu := display.cpu.mmu
+    var claimed [160]bool
+
+    for _, sprite := range mmu.spritesOnLine(ly) {
+        row := uint8(int(ly) - (int(sprite.y) - 16))
+        for col := uint8(0); col < 8; col++ {
+            lcdX := int(sprite.x) - 8 + int(col)
+            if lcdX < 0 || lcdX >= int(LCDWIDTH) || claimed[lcdX] {
+                continue
+            }
+            colorNumber := mmu.spriteColorNumber(sprite, row, col)
+            if colorNumber == 0 { // Color 0 is transparent for sprites
+                continue
+            }
+            claimed[lcdX] = true
+            if sprite.behindBackground() && backgroundColors[lcdX] != 0 {
+                continue
+            }
+            display.setPixel(uint8(lcdX), ly, display.palette[shade(mmu.objectPalette(sprite.palette()), colorNumber)])
+        }
+    }
+}
+
+// setPixel - Writes an RGBA color to the internal image
+func (display * Display) setPixel(x uint8, y uint8, color int) {
+    pixel := int(y)*int(LCDWIDTH) + int(x)
+    display.internalImage.Pix[4*pixel] = uint8((color >> 24) & 0xFF)
+    display.internalImage.Pix[4*pixel+1] = uint8((color >> 16) & 0xFF)
+    display.internalImage.Pix[4*pixel+2] = uint8((color >> 8) & 0xFF)
+    display.internalImage.Pix[4*pixel+3] = uint8(color & 0xFF)
+}
+
+func (display * Display) renderLine(ly uint8){
+    if ly == 0 {
+        display.windowLine = 0 // New frame
+    }
+    backgroundColors := display.drawBackgroundLine(ly)
+    display.drawWindowLine(ly, &backgroundColors)
+    if display.cpu.mmu.spritesEnabled() {
+        display.drawSpriteLine(ly, backgroundColors)
+    }
+}
+
+func (display * Display) readTile(tileNumber uint8) []uint8 {
+    tileAddress := uint16(tileNumber) * 16 // 16 bytes per tile, starting at 0x8000
+    return display.cpu.mmu.vram[tileAddress:tileAddress+16]
+}
+
+func (display * Display) drawTile(xTile int, yTile int, tileData []uint8){
+    screenX := xTile*16
+    screenY := yTile*16
+    for y:= 0; y<8; y++{
+        pxLow := tileData[y*2]
+        pxHigh := tileData[y*2+1]
+
+        for  x := uint8(0) ; x<8; x++ {
+            if (((pxLow >> (7-x)) & 0x1) | ((pxHigh >> (7-x))& 0x1)) > 0 {
+                pixel := (screenY+y)*256 + (screenX+int(x))
+                //fmt.Printf("Pixel: (%d)",pixel)
+                display.internalImage.Pix[4*pixel] = 0xFF
+                display.internalImage.Pix[4*pixel+1] = 0xFF
+                display.internalImage.Pix[4*pixel+2] = 0xFF
+                display.internalImage.Pix[4*pixel+3] = 0xFF
+            }
+        }
+    }
+}
+
+func (display * Display) renderScreen(){
+    for y:= 0; y<32; y++{
+        for x:=0; x<32; x++{
+            address := uint16(0x9800 + y*32 + x)
+            tileNumber := display.cpu.mmu.readBus(address)
+            tileData := display.readTile(tileNumber)
+            display.drawTile(x,y,tileData)
+        }
+    }
+}
+
+//   0  80           252                  456 (CPU Clock)
+//   +---+-----------+---------------------+  0
+//   |   |           |                     |
+//   |OAM|   Pixel   |      H-Blank        |
+//   |   |  Transfer |                     |
+//   |   |           |                     |
+//   +---+-----------+---------------------+  144
+//   |            V-Blank Time             |
+//   +-------------------------------------+  154 (LCD Lines)
+//
+func (display * Display) calculateAndSetSTAT() {
+    if display.cpu.mmu.getLY() >= 144 {
+        display.cpu.mmu.setSTATMode(0x1) // In VBLANK
+    } else {
+        if display.scanlineCounter <= 80 {
+            display.cpu.mmu.setSTATMode(0x2) // OAM search
+        } else if display.scanlineCounter > 80 && display.scanlineCounter <= 252 {
+            display.cpu.mmu.setSTATMode(0x3) // Pixel transfer state
+        } else {
+            display.cpu.mmu.setSTATMode(0x0) // H-Blank
+        }
+    }
+}
+
+// updateDisplay - Updates the display by taking in the number of cycles that the last
+// instruction took to render.
+// A single scanline takes 456 cycles to 'render'.
+// This emulator will draw to the canvas every after scanline so that the original GB-like
+// rendering is preserved
+func (display *Display) updateDisplay(cycles int){
+    display.scanlineCounter += cycles
+    display.calculateAndSetSTAT()
+
+    if display.scanlineCounter < 456 { // No new scanline just yet
+        return
+    }
+
+    if !display.cpu.mmu.showDisplay(){ // LCDC bit 7 is disabled
+        return
+    }
+
+    ly := display.cpu.mmu.getLY()
+    if ly < 144 && display.renderingEnabled { // Can only render the first 144 rows - the rest are never rendered
+        display.renderLine(ly)
+    }
+
+    //fmt.Println(y)
+    //if y == 0 { // For testing purposes, render everything once per frame
+    //   display.renderScreen()
+    //}
+
+    // Scanline ended here!
+    display.scanlineCounter -= 456 // Save the extra cycles
+    display.cpu.mmu.incrementLY()
+}
+
+func newDisplay(cpu *CPU) *Display {
+    display := new(Display)
+    display.cpu = cpu
+    display.scanlineCounter = 0
+    display.renderingEnabled = true
+    display.palette = GameBoyColorMap
+    display.internalImage = image.NewRGBA(image.Rect(0, 0, int(LCDWIDTH), int(LCDHEIGHT) ))
+    return display
+}
\ No newline at end of file
@@ -0,0 +1,220 @@
+package gmb
+
+import "testing"
+
+// windowTestDisplay - Background map 0x9800 is all tile 0 (blank) while the window map
+// at 0x9C00 has tile 1 (solid color 3) on its first row of tiles and tile 0 below it
+func windowTestDisplay() *Display {
+    cpu := testCPU()
+    mmu := cpu.mmu
+    mmu.write8(0xFF40, 0xF1) // LCD, window (map 0x9C00), 0x8000 tile data, BG
+    for i := uint16(0); i < 16; i++ {
+        mmu.vram[0x10+i] = 0xFF
+    }
+    for x := uint16(0); x < 32; x++ {
+        mmu.vram[0x1C00+x] = 1
+    }
+    return newDisplay(cpu)
+}
+
+func TestWindowPosition(t *testing.T) {
+    display := windowTestDisplay()
+    mmu := display.cpu.mmu
+    mmu.write8(0xFF4A, 2)  // WY
+    mmu.write8(0xFF4B, 17) // WX - the window starts at screen column 10
+
+    display.renderLine(0)
+    display.renderLine(1)
+    if pixelAt(display, 50, 1) != GameBoyColorMap[0] {
+        t.Errorf("The window should not be drawn above WY")
+    }
+    display.renderLine(2)
+    if pixelAt(display, 9, 2) != GameBoyColorMap[0] || pixelAt(display, 10, 2) != GameBoyColorMap[3] {
+        t.Errorf("The window should start at WX-7")
+    }
+
+    mmu.write8(0xFF40, 0xD1) // Disabling the window (LCDC bit 5)
+    display.renderLine(3)
+    if pixelAt(display, 10, 3) != GameBoyColorMap[0] {
+        t.Errorf("The window should not be drawn when LCDC bit 5 is clear")
+    }
+}
+
+func TestWindowLineCounter(t *testing.T) {
+    display := windowTestDisplay()
+    mmu := display.cpu.mmu
+    mmu.write8(0xFF4B, 7)
+
+    for ly := uint8(0); ly < 4; ly++ {
+        display.renderLine(ly)
+    }
+    mmu.write8(0xFF4B, 200) // Move the window off screen for a few lines
+    for ly := uint8(4); ly < 20; ly++ {
+        display.renderLine(ly)
+    }
+    mmu.write8(0xFF4B, 7)
+    display.renderLine(20)
+    if display.windowLine != 5 {
+        t.Errorf("Window line counter is %d, expected 5", display.windowLine)
+    }
+    if pixelAt(display, 0, 20) != GameBoyColorMap[3] {
+        t.Errorf("The window should continue with its 5th row rather than jumping to LY")
+    }
+}
+
+func TestBackgroundPalette(t *testing.T) {
+    display := windowTestDisplay()
+    mmu := display.cpu.mmu
+    mmu.write8(0xFF40, 0xD1)
+    mmu.write8(0xFF4A, 0)
+    mmu.write8(0xFF4B, 7)
+
+    mmu.write8(0xFF47, 0x27) // Color 3 -> shade 0, color 0 -> shade 3
+    if mmu.read8(0xFF47) != 0x27 {
+        t.Errorf("BGP should read back what was written")
+    }
+    display.renderLine(0)
+    if pixelAt(display, 0, 0) != GameBoyColorMap[3] {
+        t.Errorf("BGP was not applied to the background")
+    }
+
+    mmu.write8(0xFF40, 0xF1)
+    display.palette = Palettes["dmg"]
+    display.renderLine(0)
+    if pixelAt(display, 0, 0) != Palettes["dmg"][0] {
+        t.Errorf("BGP or the output palette was not applied to the window")
+    }
+}
+
+func TestBackgroundDisabledIsWhite(t *testing.T) {
+    display := windowTestDisplay()
+    mmu := display.cpu.mmu
+    mmu.write8(0xFF47, 0xFF) // Every color is black
+    mmu.write8(0xFF40, 0xF0) // BG and window off (LCDC bit 0)
+    display.renderLine(0)
+    if pixelAt(display, 0, 0) != GameBoyColorMap[0] || pixelAt(display, 80, 0) != GameBoyColorMap[0] {
+        t.Errorf("A disabled background should be white, not BGP color 0")
+    }
+}
+
+func TestParsePalette(t *testing.T) {
+    palette, err := ParsePalette("pocket")
+    if err != nil || palette != Palettes["pocket"] {
+        t.Errorf("Built in palettes should be found by name")
+    }
+    palette, err = ParsePalette("E0F8D0,88C070,#346856,081820")
+    if err != nil || palette != (Palette{0xE0F8D0FF, 0x88C070FF, 0x346856FF, 0x081820FF}) {
+        t.Errorf("Custom palette parsed as %X, %v", palette, err)
+    }
+    for _, bad := range []string{"nope", "E0F8D0,88C070,346856", "E0F8D0,88C070,346856,XYZXYZ", "E0F8D0,88C070,346856,0818200"} {
+        if _, err := ParsePalette(bad); err == nil {
+            t.Errorf("Palette %q should be rejected", bad)
+        }
+    }
+}
+
+// tileAddressingDisplay - Fills tile 0x00 at 0x8000 and 0x9000, and tile 0x80 at
+// 0x8800 with different solid colors. The background map uses tile 0x00 in
+// its first column and tile 0x80 in the second; the window map is all tile 0x80
+func tileAddressingDisplay() *Display {
+    cpu := testCPU()
+    mmu := cpu.mmu
+    solid := func(address uint16, colorNumber uint8) {
+        for row := uint16(0); row < 8; row++ {
+            mmu.vram[address-0x8000+row*2] = 0xFF * (colorNumber & 0x1)
+            mmu.vram[address-0x8000+row*2+1] = 0xFF * (colorNumber >> 1)
+        }
+    }
+    solid(0x8000, 1) // Tile 0x00, unsigned
+    solid(0x8800, 2) // Tile 0x80: unsigned 128 and signed -128
+    solid(0x9000, 3) // Tile 0x00, signed
+    for x := uint16(0); x < 32; x++ {
+        mmu.vram[0x1800+x] = uint8(x & 0x1) << 7
+        mmu.vram[0x1C00+x] = 0x80
+    }
+    mmu.write8(0xFF4A, 0)
+    mmu.write8(0xFF4B, 7+16) // The window starts at column 16
+    mmu.write8(0xFF47, 0xE4) // BGP = identity
+    return newDisplay(cpu)
+}
+
+func TestUnsignedTileAddressing(t *testing.T) {
+    display := tileAddressingDisplay()
+    display.cpu.mmu.write8(0xFF40, 0xF1) // Window with map 0x9C00, tile data 0x8000
+    display.renderLine(0)
+    if pixelAt(display, 0, 0) != GameBoyColorMap[1] || pixelAt(display, 8, 0) != GameBoyColorMap[2] {
+        t.Errorf("Background tiles should be read from 0x8000 when LCDC bit 4 is set")
+    }
+    if pixelAt(display, 16, 0) != GameBoyColorMap[2] {
+        t.Errorf("Window tiles should be read from 0x8000 when LCDC bit 4 is set")
+    }
+}
+
+func TestSignedTileAddressing(t *testing.T) {
+    display := tileAddressingDisplay()
+    display.cpu.mmu.write8(0xFF40, 0xE1) // Window with map 0x9C00, tile data 0x8800
+    display.renderLine(0)
+    if pixelAt(display, 0, 0) != GameBoyColorMap[3] {
+        t.Errorf("Tile 0 should be read from 0x9000 when LCDC bit 4 is clear")
+    }
+    if pixelAt(display, 8, 0) != GameBoyColorMap[2] || pixelAt(display, 16, 0) != GameBoyColorMap[2] {
+        t.Errorf("Tile 0x80 (-128) should be read from 0x8800 by both the background and the window")
+    }
+
+    mmu := display.cpu.mmu
+    if mmu.bgTileDataAddress(0xFF) != 0x8FF0 || mmu.bgTileDataAddress(0x7F) != 0x97F0 {
+        t.Errorf("Signed tile addresses are wrong: 0xFF at %X, 0x7F at %X", mmu.bgTileDataAddress(0xFF), mmu.bgTileDataAddress(0x7F))
+    }
+}
+
+func TestSTATModeInterrupts(t *testing.T) {
+    cpu := testCPU()
+    mmu := cpu.mmu
+    display := newDisplay(cpu)
+    mmu.write8(0xFF40, 0x91)
+    display.updateDisplay(20) // OAM search
+    mmu.write8(0xFF41, 0x08) // H-Blank source
+
+    display.updateDisplay(80) // Pixel transfer
+    if mmu.getIF() & 0x2 != 0 {
+        t.Errorf("No STAT interrupt should be requested outside of H-Blank")
+    }
+    display.updateDisplay(200) // H-Blank
+    if mmu.getIF() & 0x2 == 0 {
+        t.Errorf("Entering H-Blank should request a STAT interrupt")
+    }
+    mmu.setIF(0)
+    display.updateDisplay(40) // Still in H-Blank
+    if mmu.getIF() & 0x2 != 0 {
+        t.Errorf("The STAT interrupt should only be requested on the rising edge")
+    }
+}
+
+func TestSTATLYCInterrupt(t *testing.T) {
+    cpu := testCPU()
+    mmu := cpu.mmu
+    display := newDisplay(cpu)
+    mmu.write8(0xFF40, 0x91)
+    mmu.write8(0xFF45, 2)
+    if mmu.read8(0xFF45) != 2 {
+        t.Errorf("LYC should read back what was written")
+    }
+    mmu.write8(0xFF41, 0x40) // LY=LYC source
+
+    display.updateDisplay(456)
+    if mmu.getIF() & 0x2 != 0 {
+        t.Errorf("No STAT interrupt should be requested on LY=1")
+    }
+    display.updateDisplay(456)
+    if mmu.getIF() & 0x2 == 0 || mmu.read8(0xFF41) & 0x4 == 0 {
+        t.Errorf("LY=LYC should request a STAT interrupt and set the coincidence flag")
+    }
+
+    // STAT blocking - the LYC source keeps the line high through H-Blank of line 2
+    mmu.setIF(0)
+    mmu.write8(0xFF41, 0x48)
+    display.updateDisplay(300)
+    if mmu.getIF() & 0x2 != 0 {
+        t.Errorf("H-Blank should not request another interrupt while LY=LYC holds the line high")
+    }
+}
@@ -0,0 +1,58 @@
+package gmb
+
+// DMA - The OAM DMA controller. Writing XX to 0xFF46 copies the 160 bytes at XX00-XX9F
+// into OAM (0xFE00-0xFE9F), one byte per M-cycle (4 clock cycles).
+// While the transfer is running the CPU cannot reach anything on the memory bus; only the
+// I/O registers and HRAM (0xFF00-0xFFFF) keep working, so games run the wait loop from HRAM
+type DMA struct {
+    active bool
+    source uint16
+    bytesCopied int
+    cycles int // Clock cycles which have not been spent copying yet
+    pending bool // Started by the instruction which is still running; copying begins with the next one
+}
+
+// DMALENGTH - Number of bytes copied by a DMA transfer (the size of OAM)
+const DMALENGTH = 0xA0
+
+// start - Starts (or restarts) a transfer from source. The write to 0xFF46 is the last M-cycle
+// of its instruction, which is the one M-cycle delay, so the transfer begins with the next instruction
+func (dma *DMA) start(source uint16) {
+    dma.active = true
+    dma.source = source
+    dma.bytesCopied = 0
+    dma.cycles = 0
+    dma.pending = true
+}
+
+// updateDMA - Copies one byte for every M-cycle which was performed
+// Takes the number of cycles that were performed
+func (mmu *MMU) updateDMA(cyclesPerformed int) {
+    dma := &mmu.dma
+    if !dma.active {
+        return
+    }
+    if dma.pending { // These are the cycles of the instruction which wrote 0xFF46
+        dma.pending = false
+        return
+    }
+
+    dma.cycles += cyclesPerformed
+    for dma.cycles >= 4 && dma.active {
+        address := dma.source + uint16(dma.bytesCopied)
+        if address >= 0xE000 { // 0xE0-0xFF source pages are wired to WRAM
+            address -= 0x2000
+        }
+        mmu.oam[dma.bytesCopied] = mmu.readMemory(address)
+        dma.bytesCopied++
+        dma.cycles -= 4
+        if dma.bytesCopied == DMALENGTH {
+            dma.active = false
+        }
+    }
+}
+
+// dmaBlocksAddress - Returns true if a DMA transfer keeps the CPU from reaching the address
+func (mmu *MMU) dmaBlocksAddress(address uint16) bool {
+    return mmu.dma.active && !mmu.dma.pending && address < 0xFF00
+}
@@ -0,0 +1,66 @@
+package gmb
+
+import (
+    "fmt"
+)
+
+// ErrInvalidROM - The ROM could not be read or is not a valid Game Boy ROM
+type ErrInvalidROM struct {
+    Path string // Empty if the ROM did not come from a file
+    Reason error
+}
+
+func (err *ErrInvalidROM) Error() string {
+    if err.Path == "" {
+        return fmt.Sprintf("invalid ROM: %s", err.Reason)
+    }
+    return fmt.Sprintf("invalid ROM %s: %s", err.Path, err.Reason)
+}
+
+// ErrIllegalOpcode - The CPU tried to execute one of the opcodes which do not exist on the LR35902.
+// The real hardware locks up when this happens
+type ErrIllegalOpcode struct {
+    PC uint16 // Address of the opcode
+    Opcode uint8
+    Extended bool // True if the opcode followed a 0xCB prefix
+}
+
+func (err *ErrIllegalOpcode) Error() string {
+    if err.Extended {
+        return fmt.Sprintf("illegal opcode CB %02X at %04X", err.Opcode, err.PC)
+    }
+    return fmt.Sprintf("illegal opcode %02X at %04X", err.Opcode, err.PC)
+}
+
+// ErrUnsupportedMapper - The cartridge uses a memory bank controller which is not emulated
+type ErrUnsupportedMapper struct {
+    CartridgeType uint8 // Header byte 0x147
+}
+
+func (err *ErrUnsupportedMapper) Error() string {
+    name, ok := cartridgeTypeNames[err.CartridgeType]
+    if !ok {
+        name = "UNKNOWN"
+    }
+    return fmt.Sprintf("unsupported cartridge type %02X (%s)", err.CartridgeType, name)
+}
+
+// ErrInvalidState - A save state could not be loaded. The running game is left untouched
+type ErrInvalidState struct {
+    Reason string
+}
+
+func (err *ErrInvalidState) Error() string {
+    return fmt.Sprintf("invalid save state: %s", err.Reason)
+}
+
+// ErrInvalidExpression - A debugger expression could not be parsed
+type ErrInvalidExpression struct {
+    Expression string
+    Position int // Byte offset of the problem in Expression
+    Reason string
+}
+
+func (err *ErrInvalidExpression) Error() string {
+    return fmt.Sprintf("invalid expression %q at position %d: %s", err.Expression, err.Position, err.Reason)
+}
@@ -0,0 +1,305 @@
+package gmb
+
+import (
+    "strconv"
+    "strings"
+)
+
+// Expression - A compiled debugger expression such as "pc == 0x2A10 && a == 0x05 && [0xFF44] > 140 && !zf"
+//
+// Numbers are decimal unless they start with 0x or $. [address] reads a byte of memory
+// (without triggering watchpoints). The operators are those of C with the same precedence:
+//   || && | ^ & == != < <= > >= << >> + - * / % and the unary ! - ~
+// Comparisons and logical operators give 1 or 0. Dividing by zero gives 0
+// The variables are listed in EXPRESSIONVARIABLES
+type Expression struct {
+    text string
+    evaluate evaluator
+}
+
+// evaluator - A compiled piece of an expression
+type evaluator func(cpu *CPU) int64
+
+// EXPRESSIONVARIABLES - Help text for the names which can be used in expressions
+var EXPRESSIONVARIABLES = "a f b c d e h l af bc de hl sp pc, the flags zf nf hf cf, ime, halted, " +
+    "rombank (the bank at 0x4000-0x7FFF), instructions (executed so far) and cycles (CPU cycles so far)"
+
+// expressionVariables - What the names in an expression evaluate to
+var expressionVariables = map[string]evaluator{
+    "a": func(cpu *CPU) int64 { return int64(cpu.ra) },
+    "f": func(cpu *CPU) int64 { return int64(cpu.pswByte()) },
+    "b": func(cpu *CPU) int64 { return int64(cpu.rb) },
+    "c": func(cpu *CPU) int64 { return int64(cpu.rc) },
+    "d": func(cpu *CPU) int64 { return int64(cpu.rd) },
+    "e": func(cpu *CPU) int64 { return int64(cpu.re) },
+    "h": func(cpu *CPU) int64 { return int64(cpu.rh) },
+    "l": func(cpu *CPU) int64 { return int64(cpu.rl) },
+    "af": func(cpu *CPU) int64 { return int64(cpu.ra)<<8 | int64(cpu.pswByte()) },
+    "bc": func(cpu *CPU) int64 { return int64(cpu.getBC()) },
+    "de": func(cpu *CPU) int64 { return int64(cpu.getDE()) },
+    "hl": func(cpu *CPU) int64 { return int64(cpu.getHL()) },
+    "sp": func(cpu *CPU) int64 { return int64(cpu.stackPointer) },
+    "pc": func(cpu *CPU) int64 { return int64(cpu.programCounter) },
+    "zf": func(cpu *CPU) int64 { return boolValue(cpu.zero) },
+    "nf": func(cpu *CPU) int64 { return boolValue(cpu.subtract) },
+    "hf": func(cpu *CPU) int64 { return boolValue(cpu.halfCarry) },
+    "cf": func(cpu *CPU) int64 { return boolValue(cpu.carry) },
+    "ime": func(cpu *CPU) int64 { return boolValue(cpu.inte) },
+    "halted": func(cpu *CPU) int64 { return boolValue(cpu.halted) },
+    "rombank": func(cpu *CPU) int64 { return int64(cpu.mmu.cart.mbc.currentROMBank()) },
+    "instructions": func(cpu *CPU) int64 { return int64(cpu.instructionsExecuted) },
+    "cycles": func(cpu *CPU) int64 { return int64(cpu.timer.cpuCycles) },
+}
+
+// binaryOperators - From the lowest to the highest precedence
+var binaryOperators = [][]string{
+    {"||"}, {"&&"}, {"|"}, {"^"}, {"&"}, {"==", "!="}, {"<", "<=", ">", ">="}, {"<<", ">>"}, {"+", "-"}, {"*", "/", "%"},
+}
+
+// expressionOperators - Every operator token; two character operators go first so that they win
+var expressionOperators = []string{
+    "||", "&&", "==", "!=", "<=", ">=", "<<", ">>",
+    "|", "^", "&", "<", ">", "+", "-", "*", "/", "%", "!", "~", "(", ")", "[", "]",
+}
+
+func boolValue(value bool) int64 {
+    if value {
+        return 1
+    }
+    return 0
+}
+
+// expressionToken - A number, name or operator and where it starts in the text
+type expressionToken struct {
+    text string
+    position int
+}
+
+// expressionParser - A recursive descent parser over the tokens of one expression
+type expressionParser struct {
+    text string
+    tokens []expressionToken
+    next int
+}
+
+// ParseExpression - Compiles an expression (see Expression)
+func ParseExpression(text string) (*Expression, error) {
+    tokens, err := tokenizeExpression(text)
+    if err != nil {
+        return nil, err
+    }
+    parser := expressionParser{text: text, tokens: tokens}
+    evaluate, err := parser.parseBinary(0)
+    if err != nil {
+        return nil, err
+    }
+    if parser.next < len(tokens) {
+        return nil, parser.errorAt(tokens[parser.next].position, "unexpected "+tokens[parser.next].text)
+    }
+    return &Expression{text, evaluate}, nil
+}
+
+// String - The expression as it was written
+func (expression *Expression) String() string {
+    return expression.text
+}
+
+// tokenizeExpression - Splits the text into tokens. Names are lower cased
+func tokenizeExpression(text string) ([]expressionToken, error) {
+    var tokens []expressionToken
+    for position := 0; position < len(text); {
+        char := text[position]
+        start := position
+        switch {
+        case char == ' ' || char == '\t':
+            position++
+            continue
+        case isNameCharacter(char) || char == '$':
+            position++
+            for position < len(text) && isNameCharacter(text[position]) {
+                position++
+            }
+        default:
+            for _, operator := range expressionOperators {
+                if strings.HasPrefix(text[position:], operator) {
+                    position += len(operator)
+                    break
+                }
+            }
+            if position == start {
+                return nil, &ErrInvalidExpression{text, position, "unexpected character " + string(char)}
+            }
+        }
+        tokens = append(tokens, expressionToken{strings.ToLower(text[start:position]), start})
+    }
+    return tokens, nil
+}
+
+func isNameCharacter(char byte) bool {
+    return char >= 'a' && char <= 'z' || char >= 'A' && char <= 'Z' || char >= '0' && char <= '9' || char == '_'
+}
+
+func (parser *expressionParser) errorAt(position int, reason string) error {
+    return &ErrInvalidExpression{parser.text, position, reason}
+}
+
+// peek - The next token, or "" at the end
+func (parser *expressionParser) peek() string {
+    if parser.next < len(parser.tokens) {
+        return parser.tokens[parser.next].text
+    }
+    return ""
+}
+
+// position - Where the next token starts (the end of the text at the end)
+func (parser *expressionParser) position() int {
+    if parser.next < len(parser.tokens) {
+        return parser.tokens[parser.next].position
+    }
+    return len(parser.text)
+}
+
+// expect - Consumes the closing bracket of a (...) or [...]
+func (parser *expressionParser) expect(token string) error {
+    if parser.peek() != token {
+        return parser.errorAt(parser.position(), "expected "+token)
+    }
+    parser.next++
+    return nil
+}
+
+// parseBinary - Parses the operators of binaryOperators[level] and everything that binds tighter
+func (parser *expressionParser) parseBinary(level int) (evaluator, error) {
+    if level == len(binaryOperators) {
+        return parser.parseUnary()
+    }
+    left, err := parser.parseBinary(level + 1)
+    if err != nil {
+        return nil, err
+    }
+    for {
+        operator := parser.peek()
+        found := false
+        for _, candidate := range binaryOperators[level] {
+            found = found || operator == candidate
+        }
+        if !found {
+            return left, nil
+        }
+        parser.next++
+        right, err := parser.parseBinary(level + 1)
+        if err != nil {
+            return nil, err
+        }
+        left = binaryEvaluator(operator, left, right)
+    }
+}
+
+// binaryEvaluator - Combines two compiled operands. && and || short circuit
+func binaryEvaluator(operator string, left evaluator, right evaluator) evaluator {
+    switch operator {
+    case "||":
+        return func(cpu *CPU) int64 { return boolValue(left(cpu) != 0 || right(cpu) != 0) }
+    case "&&":
+        return func(cpu *CPU) int64 { return boolValue(left(cpu) != 0 && right(cpu) != 0) }
+    case "|":
+        return func(cpu *CPU) int64 { return left(cpu) | right(cpu) }
+    case "^":
+        return func(cpu *CPU) int64 { return left(cpu) ^ right(cpu) }
+    case "&":
+        return func(cpu *CPU) int64 { return left(cpu) & right(cpu) }
+    case "==":
+        return func(cpu *CPU) int64 { return boolValue(left(cpu) == right(cpu)) }
+    case "!=":
+        return func(cpu *CPU) int64 { return boolValue(left(cpu) != right(cpu)) }
+    case "<":
+        return func(cpu *CPU) int64 { return boolValue(left(cpu) < right(cpu)) }
+    case "<=":
+        return func(cpu *CPU) int64 { return boolValue(left(cpu) <= right(cpu)) }
+    case ">":
+        return func(cpu *CPU) int64 { return boolValue(left(cpu) > right(cpu)) }
+    case ">=":
+        return func(cpu *CPU) int64 { return boolValue(left(cpu) >= right(cpu)) }
+    case "<<":
+        return func(cpu *CPU) int64 { return left(cpu) << uint64(right(cpu) & 63) }
+    case ">>":
+        return func(cpu *CPU) int64 { return left(cpu) >> uint64(right(cpu) & 63) }
+    case "+":
+        return func(cpu *CPU) int64 { return left(cpu) + right(cpu) }
+    case "-":
+        return func(cpu *CPU) int64 { return left(cpu) - right(cpu) }
+    case "*":
+        return func(cpu *CPU) int64 { return left(cpu) * right(cpu) }
+    case "/":
+        return func(cpu *CPU) int64 {
+            if divisor := right(cpu); divisor != 0 {
+                return left(cpu) / divisor
+            }
+            return 0
+        }
+    }
+    return func(cpu *CPU) int64 { // %
+        if divisor := right(cpu); divisor != 0 {
+            return left(cpu) % divisor
+        }
+        return 0
+    }
+}
+
+// parseUnary - ! - ~ followed by an operand
+func (parser *expressionParser) parseUnary() (evaluator, error) {
+    operator := parser.peek()
+    if operator != "!" && operator != "-" && operator != "~" {
+        return parser.parsePrimary()
+    }
+    parser.next++
+    operand, err := parser.parseUnary()
+    if err != nil {
+        return nil, err
+    }
+    switch operator {
+    case "!":
+        return func(cpu *CPU) int64 { return boolValue(operand(cpu) == 0) }, nil
+    case "-":
+        return func(cpu *CPU) int64 { return -operand(cpu) }, nil
+    }
+    return func(cpu *CPU) int64 { return ^operand(cpu) }, nil
+}
+
+// parsePrimary - A number, a variable, (expression) or [address]
+func (parser *expressionParser) parsePrimary() (evaluator, error) {
+    position := parser.position()
+    token := parser.peek()
+    parser.next++
+    switch {
+    case token == "":
+        return nil, parser.errorAt(position, "unexpected end of expression")
+    case token == "(" || token == "[":
+        inner, err := parser.parseBinary(0)
+        if err != nil {
+            return nil, err
+        }
+        if token == "(" {
+            return inner, parser.expect(")")
+        }
+        return func(cpu *CPU) int64 { return int64(cpu.mmu.peek(uint16(inner(cpu)))) }, parser.expect("]")
+    case token[0] >= '0' && token[0] <= '9' || token[0] == '$':
+        var value uint64
+        var err error
+        if strings.HasPrefix(token, "0x") {
+            value, err = strconv.ParseUint(token[2:], 16, 32)
+        } else if token[0] == '$' {
+            value, err = strconv.ParseUint(token[1:], 16, 32)
+        } else {
+            value, err = strconv.ParseUint(token, 10, 32)
+        }
+        if err != nil {
+            return nil, parser.errorAt(position, "invalid number "+token)
+        }
+        return func(cpu *CPU) int64 { return int64(value) }, nil
+    }
+    if variable, ok := expressionVariables[token]; ok {
+        return variable, nil
+    }
+    return nil, parser.errorAt(position, "unknown name "+token)
+}
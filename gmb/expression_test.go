@@ -0,0 +1,86 @@
+package gmb
+
+import (
+    "bytes"
+    "strings"
+    "testing"
+)
+
+func TestExpressions(t *testing.T) {
+    debugger := debuggerMachine(t)
+    cpu := debugger.gb.cpu
+    cpu.ra, cpu.rh, cpu.rl = 0x05, 0xC0, 0x10
+    cpu.zero, cpu.carry = false, true
+    cpu.mmu.internalRAM[0xFF44] = 141
+    cpu.mmu.wram[0x10] = 0x42
+
+    expressions := map[string]int64{
+        "pc == 0x100 && a == 0x05 && [0xFF44] > 140 && !zf": 1,
+        "pc == 0x100 && a == 0x06":                          0,
+        "1 + 2 * 3":                                         7,
+        "(1 + 2) * 3":                                       9,
+        "[hl] == $42":                                       1,
+        "hl + 1":                                            0xC011,
+        "cf | zf << 1":                                      1,
+        "-a + ~0 & 0xFF":                                   -5 + 0xFF,
+        "10 / 0 + 10 % 0":                                   0,
+        "1 < 2 == 1":                                        1,
+        "rombank":                                           1,
+        "A == 5 || PC == 0":                                 1,
+    }
+    for text, expected := range expressions {
+        value, err := debugger.Evaluate(text)
+        if err != nil {
+            t.Errorf("%s: %s", text, err)
+        } else if value != expected {
+            t.Errorf("%s evaluated to %d, expected %d", text, value, expected)
+        }
+    }
+
+    debugger.Step()
+    if value, _ := debugger.Evaluate("instructions == 1 && cycles > 0"); value != 1 {
+        t.Errorf("instructions and cycles should count the executed instruction")
+    }
+}
+
+func TestInvalidExpressions(t *testing.T) {
+    for _, text := range []string{"", "a ==", "(a", "[hl", "a b", "x == 1", "a # 1", "0xZZ"} {
+        if _, err := ParseExpression(text); err == nil {
+            t.Errorf("%q should not parse", text)
+        } else if _, ok := err.(*ErrInvalidExpression); !ok {
+            t.Errorf("%q: expected ErrInvalidExpression, got %v", text, err)
+        }
+    }
+}
+
+func TestConditionalBreakpoints(t *testing.T) {
+    debugger := debuggerMachine(t)
+    condition, _ := ParseExpression("a == 6")
+    debugger.AddBreakpoint(Breakpoint{AnyAddress: true, Condition: condition})
+    reason, err := debugger.Continue()
+    expectStop(t, debugger, reason, err, StopBreakpoint, 0x0301)
+
+    // The JR loop at 0103 matches every other instruction; ignore the first two matches
+    debugger = debuggerMachine(t)
+    id := debugger.AddBreakpoint(Breakpoint{Address: 0x0103, IgnoreCount: 2})
+    reason, err = debugger.Continue()
+    expectStop(t, debugger, reason, err, StopBreakpoint, 0x0103)
+    if hits := debugger.BreakpointHits(); len(hits) != 1 || hits[0].ID != id || hits[0].Hits != 3 {
+        t.Errorf("Unexpected hits %v", hits)
+    }
+}
+
+func TestTracepoints(t *testing.T) {
+    debugger := debuggerMachine(t)
+    var log bytes.Buffer
+    debugger.SetTraceOutput(&log)
+    debugger.AddBreakpoint(Breakpoint{Address: 0x0300, Trace: true})
+    reason, err := debugger.RunTo(0x0103)
+    expectStop(t, debugger, reason, err, StopStep, 0x0103)
+    if !strings.HasPrefix(log.String(), "Trace 1 (hit 1): PC=0300") || strings.Count(log.String(), "\n") != 1 {
+        t.Errorf("Unexpected trace output %q", log.String())
+    }
+    if breakpoints := debugger.Breakpoints(); breakpoints[0].Hits != 1 {
+        t.Errorf("The tracepoint should count its hit")
+    }
+}
@@ -0,0 +1,301 @@
+package gmb
+
+import (
+    "bytes"
+    "image"
+    "io"
+    "io/ioutil"
+    "time"
+)
+
+// GameBoy - A complete DMG machine: CPU, memory, timers, LCD and a cartridge.
+// Every GameBoy is independent, so any number of them can run in the same process
+type GameBoy struct {
+    cpu *CPU
+    display *Display
+    rom []uint8
+    options options
+
+    rewind *RewindBuffer // nil unless WithRewind was given
+    framesSinceSnapshot int
+    atNewestSnapshot bool // The machine is in the state of the newest rewind snapshot
+}
+
+// options - Per machine settings, changed through the With* Options
+type options struct {
+    debugMode bool
+    renderingEnabled bool
+    savePath string
+    serialOutput io.Writer
+    clock ClockSource
+    rumble RumbleCallback
+    palette Palette
+    sampleRate int
+    rewindSeconds float64
+    rewindInterval int
+}
+
+// Option - Configures a GameBoy when it is created with New or Load
+type Option func(*options)
+
+// WithDebug - Pretty prints every instruction to stdout as it is executed (slow)
+func WithDebug(enabled bool) Option {
+    return func(opts *options) { opts.debugMode = enabled }
+}
+
+// WithDisplay - Turns the rendering of pixels on or off. The LCD timing (LY, STAT, V-Blank)
+// keeps running either way, so headless machines behave the same as ones with a screen
+func WithDisplay(enabled bool) Option {
+    return func(opts *options) { opts.renderingEnabled = enabled }
+}
+
+// WithSaveFile - Loads battery-backed cartridge RAM from the file and lets FlushSave write it back.
+// Ignored for cartridges without a battery
+func WithSaveFile(path string) Option {
+    return func(opts *options) { opts.savePath = path }
+}
+
+// WithSerialOutput - Every byte written to the serial port (0xFF01) is copied to the writer.
+// The test ROMs report their results this way
+func WithSerialOutput(writer io.Writer) Option {
+    return func(opts *options) { opts.serialOutput = writer }
+}
+
+// WithClock - The source of wall clock time for cartridges with a real time clock (default: time.Now)
+func WithClock(clock ClockSource) Option {
+    return func(opts *options) { opts.clock = clock }
+}
+
+// WithRumble - Called whenever the rumble motor of the cartridge switches on or off
+func WithRumble(callback RumbleCallback) Option {
+    return func(opts *options) { opts.rumble = callback }
+}
+
+// WithPalette - The RGBA colors that the four shades are shown as (default: GameBoyColorMap).
+// See Palettes and ParsePalette for the built in choices
+func WithPalette(palette Palette) Option {
+    return func(opts *options) { opts.palette = palette }
+}
+
+// WithSampleRate - Produces stereo audio at the given number of samples per second (for example 44100).
+// The samples pile up until they are taken with AudioSamples. The default of 0 produces no audio
+func WithSampleRate(rate int) Option {
+    return func(opts *options) { opts.sampleRate = rate }
+}
+
+// WithRewind - Keeps snapshots for the last seconds of emulated time so that StepBack can
+// go back. A snapshot is taken after every everyFrames frames; 1 allows stepping back
+// frame by frame, larger values use less memory and time
+func WithRewind(seconds float64, everyFrames int) Option {
+    return func(opts *options) {
+        opts.rewindSeconds = seconds
+        opts.rewindInterval = everyFrames
+    }
+}
+
+// New - Creates a GameBoy which runs the ROM image. ROMs with a bad header checksum are
+// rejected with an ErrInvalidROM; they would not boot on the real hardware either.
+// Cartridges which need an unemulated controller return an ErrUnsupportedMapper
+func New(rom []byte, opts ...Option) (*GameBoy, error) {
+    header, err := ParseHeader(rom)
+    if err != nil {
+        return nil, &ErrInvalidROM{"", err}
+    }
+    if err := header.ValidateHeaderChecksum(); err != nil {
+        return nil, &ErrInvalidROM{"", err}
+    }
+
+    gb := new(GameBoy)
+    gb.rom = rom
+    gb.options = options{renderingEnabled: true, clock: time.Now, palette: GameBoyColorMap}
+    for _, opt := range opts {
+        opt(&gb.options)
+    }
+
+    cart, err := gb.newCartridge()
+    if err != nil {
+        return nil, err
+    }
+    if gb.options.savePath != "" {
+        if err := cart.loadSave(gb.options.savePath); err != nil {
+            return nil, err
+        }
+    }
+    gb.powerOn(cart)
+    if gb.options.rewindSeconds > 0 && gb.options.rewindInterval > 0 {
+        framesPerSecond := float64(CLOCKSPEED) / float64(CYCLESPERFRAME)
+        gb.rewind = newRewindBuffer(int(gb.options.rewindSeconds * framesPerSecond) / gb.options.rewindInterval)
+    }
+    return gb, nil
+}
+
+// Load - Reads the ROM file and creates a GameBoy which runs it. Battery-backed RAM is
+// kept in <rom>.sav unless another WithSaveFile option is given
+func Load(romName string, opts ...Option) (*GameBoy, error) {
+    rom, err := ioutil.ReadFile(romName)
+    if err != nil {
+        return nil, &ErrInvalidROM{romName, err}
+    }
+
+    opts = append([]Option{WithSaveFile(SavePathForROM(romName))}, opts...)
+    gb, err := New(rom, opts...)
+    if invalid, ok := err.(*ErrInvalidROM); ok {
+        invalid.Path = romName
+    }
+    return gb, err
+}
+
+// newCartridge - Builds the cartridge with the options which apply to it
+func (gb *GameBoy) newCartridge() (*Cartridge, error) {
+    cart, err := newCartridgeWithClock(gb.rom, gb.options.clock)
+    if err != nil {
+        return nil, err
+    }
+    cart.setRumbleCallback(gb.options.rumble)
+    return cart, nil
+}
+
+// powerOn - Wires up a fresh CPU, memory and display around the cartridge
+func (gb *GameBoy) powerOn(cart *Cartridge) {
+    gb.cpu = newCPU()
+    gb.cpu.debugMode = gb.options.debugMode
+    gb.cpu.mmu.cart = cart
+    gb.cpu.mmu.serialOutput = gb.options.serialOutput
+    gb.display = newDisplay(gb.cpu)
+    gb.display.renderingEnabled = gb.options.renderingEnabled
+    gb.display.palette = gb.options.palette
+    gb.cpu.mmu.apu.setSampleRate(gb.options.sampleRate)
+}
+
+// Reset - Presses the power button off and on again. Everything is reset except for the
+// battery-backed cartridge RAM (and clock), which survives just like on the real hardware
+func (gb *GameBoy) Reset() {
+    cart, _ := gb.newCartridge() // Cannot fail, it worked the first time
+    old := gb.cpu.mmu.cart
+    if ram := old.batteryRAM(); ram != nil {
+        cart.batteryRAM().loadSaveData(ram.saveData())
+    }
+    cart.savePath = old.savePath
+    cart.ramDirty = old.ramDirty
+    oldAPU := gb.cpu.mmu.apu
+    gb.powerOn(cart)
+    if oldAPU.recorder != nil { // Keep recording across the reset
+        gb.cpu.mmu.apu.resumeRecording(oldAPU.recorder, oldAPU.sampleRate)
+    }
+}
+
+// Step - Executes a single instruction (and the hardware which runs alongside it)
+// Returns the number of cycles the instruction took. Once an error was returned,
+// the machine is stuck and every further call returns the same error
+func (gb *GameBoy) Step() (int, error) {
+    cycles, err := gb.cpu.step()
+    if err != nil {
+        return 0, err
+    }
+    gb.display.updateDisplay(cycles) // This may trip interrupts so it goes before the interrupt dispatching function
+    gb.cpu.checkForInterrupts()
+    return cycles, nil
+}
+
+// RunFrame - Runs the machine for the length of one frame (70224 cycles)
+func (gb *GameBoy) RunFrame() error {
+    cycleCounter := 0 // May cause up to ~28 extra cycles to be run during this frame render
+    for cycleCounter <= CYCLESPERFRAME {
+        cycles, err := gb.Step()
+        if err != nil {
+            return err
+        }
+        cycleCounter += cycles
+    }
+    gb.captureRewindSnapshot()
+    return nil
+}
+
+// captureRewindSnapshot - Called after every frame; takes a snapshot every rewindInterval frames
+func (gb *GameBoy) captureRewindSnapshot() {
+    if gb.rewind == nil {
+        return
+    }
+    gb.atNewestSnapshot = false
+    gb.framesSinceSnapshot++
+    if gb.framesSinceSnapshot < gb.options.rewindInterval {
+        return
+    }
+    gb.framesSinceSnapshot = 0
+
+    var state bytes.Buffer
+    if err := gb.SaveState(&state); err != nil {
+        return // Only fails when writing fails, which a bytes.Buffer does not
+    }
+    gb.rewind.push(state.Bytes())
+    gb.atNewestSnapshot = true
+}
+
+// StepBack - Goes back to the newest rewind snapshot that is older than the current frame and
+// removes it from the buffer, so calling it repeatedly keeps going further back. With
+// WithRewind(seconds, 1) this steps back a single frame. Sound produced since then is dropped.
+// Returns ErrNothingToRewind once the buffer is empty
+func (gb *GameBoy) StepBack() error {
+    if gb.rewind == nil {
+        return ErrNothingToRewind
+    }
+    if gb.atNewestSnapshot {
+        gb.rewind.pop() // That is where the machine already is
+        gb.atNewestSnapshot = false
+    }
+    state := gb.rewind.pop()
+    if state == nil {
+        return ErrNothingToRewind
+    }
+    if err := gb.LoadState(bytes.NewReader(state)); err != nil {
+        return err
+    }
+    gb.framesSinceSnapshot = 0
+    gb.cpu.mmu.apu.takeSamples()
+    return nil
+}
+
+// Framebuffer - The image shown on the LCD. It is updated in place one line at a time,
+// so it should be copied out between frames rather than while a frame is running
+func (gb *GameBoy) Framebuffer() *image.RGBA {
+    return gb.display.internalImage
+}
+
+// AudioSamples - Returns the audio produced since the last call as interleaved
+// left/right 16-bit samples at the WithSampleRate rate
+func (gb *GameBoy) AudioSamples() []int16 {
+    return gb.cpu.mmu.apu.takeSamples()
+}
+
+// RecordAudio - Streams everything the sound hardware plays to a 16-bit stereo PCM WAV file
+// until StopRecording is called. channels picks which channels are heard (AllChannels for the
+// normal mix, Noise for only the noise channel, ...); playback through AudioSamples is not affected
+func (gb *GameBoy) RecordAudio(writer io.WriteSeeker, channels Channels) error {
+    gb.StopRecording()
+    return gb.cpu.mmu.apu.startRecording(writer, channels)
+}
+
+// StopRecording - Fills in the WAV header. Returns the first error that happened while recording
+func (gb *GameBoy) StopRecording() error {
+    return gb.cpu.mmu.apu.stopRecording()
+}
+
+// SetButtons - Sets which buttons are currently held down
+func (gb *GameBoy) SetButtons(buttons Buttons) {
+    gb.cpu.mmu.setButtons(buttons)
+}
+
+// FlushSave - Writes battery-backed cartridge RAM to the save file if it changed since the last flush
+func (gb *GameBoy) FlushSave() error {
+    return gb.cpu.mmu.cart.flushSave()
+}
+
+// Header - The header of the cartridge which is inserted
+func (gb *GameBoy) Header() *CartridgeHeader {
+    return gb.cpu.mmu.cart.header
+}
+
+// CPUState - One line summary of the CPU registers, useful when reporting why the machine stopped
+func (gb *GameBoy) CPUState() string {
+    return gb.cpu.stateString()
+}
@@ -0,0 +1,53 @@
+package gmb
+
+import "testing"
+
+// validROM - A testROM with a correct header checksum, so that New accepts it
+func validROM(cartridgeType uint8, banks int, ramSize uint8) []uint8 {
+    rom := testROM(cartridgeType, banks, ramSize)
+    header, _ := ParseHeader(rom)
+    rom[0x14D] = header.computedHeaderChecksum
+    return rom
+}
+
+func TestNewRejectsBadHeader(t *testing.T) {
+    rom := validROM(0x00, 2, 0x00)
+    rom[0x14D]++
+    _, err := New(rom)
+    if _, ok := err.(*ErrInvalidROM); !ok {
+        t.Errorf("Expected ErrInvalidROM, got %v", err)
+    }
+}
+
+func TestResetKeepsBatteryRAM(t *testing.T) {
+    gb, err := New(validROM(0x03, 2, 0x02))
+    if err != nil {
+        t.Fatal(err)
+    }
+    gb.cpu.mmu.write8(0x0000, 0x0A)
+    gb.cpu.mmu.write8(0xA000, 0x99)
+    gb.cpu.mmu.write8(0xC000, 0x55)
+    if err := gb.RunFrame(); err != nil {
+        t.Fatal(err)
+    }
+
+    gb.Reset()
+    gb.cpu.mmu.write8(0x0000, 0x0A)
+    if gb.cpu.mmu.read8(0xA000) != 0x99 {
+        t.Errorf("Battery-backed RAM should survive a reset")
+    }
+    if gb.cpu.mmu.read8(0xC000) != 0x00 || gb.cpu.programCounter != 0x100 {
+        t.Errorf("Everything else should be back to its power on state")
+    }
+}
+
+func TestMachinesAreIndependent(t *testing.T) {
+    quiet, _ := New(validROM(0x00, 2, 0x00))
+    verbose, _ := New(validROM(0x00, 2, 0x00), WithDebug(true), WithDisplay(false))
+    if quiet.cpu.debugMode || !quiet.display.renderingEnabled {
+        t.Errorf("Options of one machine leaked into another")
+    }
+    if !verbose.cpu.debugMode || verbose.display.renderingEnabled {
+        t.Errorf("Options were not applied")
+    }
+}
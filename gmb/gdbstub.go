@@ -0,0 +1,419 @@
+package gmb
+
+import (
+    "bufio"
+    "encoding/hex"
+    "fmt"
+    "net"
+    "strconv"
+    "strings"
+    "sync/atomic"
+)
+
+// GDBServer - Exposes a GameBoy through the GDB Remote Serial Protocol, so that GDB (or an IDE
+// which talks to gdbserver) can debug the game. One client is served at a time.
+//
+// GDB does not know the LR35902, so the registers are described by target.xml as
+// AF, BC, DE, HL, SP and PC, each 16 bits little endian. Memory is the CPU's view of the
+// address space. Supported: ? g G p P m M s c Z0/z0 (Z1 is the same) Z2-Z4/z2-z4, Ctrl+C,
+// detach and kill
+type GDBServer struct {
+    debugger *Debugger
+    listener net.Listener
+    closed int32 // Set by Close, which may be called from another goroutine
+}
+
+// gdbConnection - The state of one client
+type gdbConnection struct {
+    server *GDBServer
+    conn net.Conn
+    writer *bufio.Writer
+    noAck bool // QStartNoAckMode was negotiated
+    breakpoints map[uint16]int // Address to Debugger breakpoint ID
+    watchpoints map[string]int // "type,addr,length" to Debugger watchpoint ID
+}
+
+// gdbPacket - A packet received from the client
+type gdbPacket struct {
+    data string
+    valid bool // The checksum matched
+}
+
+// GDBTARGETXML - The register description sent to GDB
+var GDBTARGETXML = `<?xml version="1.0"?>
+<!DOCTYPE target SYSTEM "gdb-target.dtd">
+<target version="1.0">
+  <feature name="org.gnu.gdb.lr35902.core">
+    <reg name="af" bitsize="16" type="int"/>
+    <reg name="bc" bitsize="16" type="int"/>
+    <reg name="de" bitsize="16" type="int"/>
+    <reg name="hl" bitsize="16" type="int"/>
+    <reg name="sp" bitsize="16" type="data_ptr"/>
+    <reg name="pc" bitsize="16" type="code_ptr"/>
+  </feature>
+</target>
+`
+
+// GDBREGISTERCOUNT - Number of registers in target.xml
+var GDBREGISTERCOUNT = 6
+
+// ListenGDB - Starts listening for GDB on the address (e.g. "localhost:2345"). Call Serve to accept clients
+func ListenGDB(gb *GameBoy, address string) (*GDBServer, error) {
+    listener, err := net.Listen("tcp", address)
+    if err != nil {
+        return nil, err
+    }
+    return &GDBServer{debugger: NewDebugger(gb), listener: listener}, nil
+}
+
+// Addr - The address the server is listening on
+func (server *GDBServer) Addr() net.Addr {
+    return server.listener.Addr()
+}
+
+// Close - Stops listening. Serve returns once the current client disconnects
+func (server *GDBServer) Close() error {
+    atomic.StoreInt32(&server.closed, 1)
+    return server.listener.Close()
+}
+
+// Serve - Serves clients one after another until the server is closed or a client sends kill (k).
+// Returns nil in both cases
+func (server *GDBServer) Serve() error {
+    for {
+        conn, err := server.listener.Accept()
+        if atomic.LoadInt32(&server.closed) != 0 {
+            if conn != nil {
+                conn.Close()
+            }
+            return nil
+        }
+        if err != nil {
+            return err
+        }
+        killed := server.serveConnection(conn)
+        conn.Close()
+        if killed {
+            return nil
+        }
+    }
+}
+
+// serveConnection - Handles packets until the client goes away. Returns true if it sent kill
+func (server *GDBServer) serveConnection(conn net.Conn) bool {
+    client := gdbConnection{server: server, conn: conn, writer: bufio.NewWriter(conn),
+        breakpoints: make(map[uint16]int), watchpoints: make(map[string]int)}
+    defer client.removeStops()
+    packets := make(chan gdbPacket)
+    finished := make(chan struct{})
+    defer close(finished)
+    go client.readPackets(packets, finished)
+
+    for packet := range packets {
+        if packet.valid && packet.data != "" && (packet.data[0] == 'c' || packet.data[0] == 's') {
+            client.server.debugger.ResetInterrupt() // Before the ack, after which GDB may send Ctrl+C
+        }
+        if !client.noAck {
+            if !packet.valid {
+                client.writer.WriteByte('-')
+                client.writer.Flush()
+                continue
+            }
+            client.writer.WriteByte('+')
+            client.writer.Flush() // Before a c which may run for a long time
+        }
+        reply, done := client.handle(packet.data)
+        if packet.data == "QStartNoAckMode" {
+            client.noAck = true // From the OK on, neither side acknowledges packets
+        }
+        if packet.data != "k" { // Kill is the one packet without a reply
+            client.send(reply)
+        }
+        if done {
+            return packet.data == "k"
+        }
+    }
+    return false
+}
+
+// readPackets - Reads packets from the client until it disconnects. Ctrl+C (0x03) interrupts
+// the running program straight away, everything else goes to the serving goroutine until it finishes
+func (client *gdbConnection) readPackets(packets chan<- gdbPacket, finished <-chan struct{}) {
+    defer close(packets)
+    defer client.server.debugger.Interrupt() // A client which goes away during c should not leave the game running
+    reader := bufio.NewReader(client.conn)
+    for {
+        b, err := reader.ReadByte()
+        if err != nil {
+            return
+        }
+        switch b {
+        case 0x03:
+            client.server.debugger.Interrupt()
+        case '$':
+            data, err := reader.ReadString('#')
+            if err != nil {
+                return
+            }
+            data = data[:len(data)-1]
+            high, err := reader.ReadByte()
+            if err != nil {
+                return
+            }
+            low, err := reader.ReadByte()
+            if err != nil {
+                return
+            }
+            expected, err := strconv.ParseUint(string([]byte{high, low}), 16, 8)
+            select {
+            case packets <- gdbPacket{data, err == nil && uint8(expected) == gdbChecksum(data)}:
+            case <-finished:
+                return
+            }
+        }
+        // Acknowledgements from the client (+ and -) are not needed; replies are never resent
+    }
+}
+
+// gdbChecksum - The sum of the packet data modulo 256
+func gdbChecksum(data string) uint8 {
+    var sum uint8
+    for i := 0; i < len(data); i++ {
+        sum += data[i]
+    }
+    return sum
+}
+
+// send - Writes a reply packet
+func (client *gdbConnection) send(data string) {
+    fmt.Fprintf(client.writer, "$%s#%02x", data, gdbChecksum(data))
+    client.writer.Flush()
+}
+
+// removeStops - Takes out the breakpoints and watchpoints of a client which went away
+func (client *gdbConnection) removeStops() {
+    for _, id := range client.breakpoints {
+        client.server.debugger.RemoveBreakpoint(id)
+    }
+    for _, id := range client.watchpoints {
+        client.server.debugger.RemoveWatchpoint(id)
+    }
+}
+
+// handle - Returns the reply to a packet and whether the connection should be closed afterwards.
+// Unsupported packets get an empty reply, as the protocol asks for
+func (client *gdbConnection) handle(data string) (string, bool) {
+    debugger := client.server.debugger
+    if data == "" {
+        return "", false
+    }
+    switch data[0] {
+    case '?':
+        return "S05", false
+    case 'g':
+        return client.readRegisters(), false
+    case 'G':
+        return client.writeRegisters(data[1:]), false
+    case 'p':
+        number, err := strconv.ParseUint(data[1:], 16, 8)
+        if err != nil || int(number) >= GDBREGISTERCOUNT {
+            return "E01", false
+        }
+        return client.readRegisters()[number*4 : number*4+4], false
+    case 'P':
+        parts := strings.SplitN(data[1:], "=", 2)
+        number, err := strconv.ParseUint(parts[0], 16, 8)
+        if err != nil || len(parts) != 2 || len(parts[1]) != 4 || int(number) >= GDBREGISTERCOUNT {
+            return "E01", false
+        }
+        registers := []byte(client.readRegisters())
+        copy(registers[number*4:], parts[1])
+        return client.writeRegisters(string(registers)), false
+    case 'm':
+        address, length, ok := parseGDBRange(data[1:])
+        if !ok {
+            return "E01", false
+        }
+        return hex.EncodeToString(debugger.ReadMemory(address, length)), false
+    case 'M':
+        parts := strings.SplitN(data[1:], ":", 2)
+        address, length, ok := parseGDBRange(parts[0])
+        if !ok || len(parts) != 2 {
+            return "E01", false
+        }
+        bytes, err := hex.DecodeString(parts[1])
+        if err != nil || len(bytes) != length {
+            return "E01", false
+        }
+        debugger.WriteMemory(address, bytes)
+        return "OK", false
+    case 's', 'c':
+        if len(data) > 1 { // Resume at an address
+            address, err := strconv.ParseUint(data[1:], 16, 16)
+            if err != nil {
+                return "E01", false
+            }
+            registers := debugger.Registers()
+            registers.PC = uint16(address)
+            debugger.SetRegisters(registers)
+        }
+        if data[0] == 's' {
+            return client.stopReply(debugger.Step()), false
+        }
+        return client.stopReply(debugger.Continue()), false
+    case 'Z', 'z':
+        return client.setStop(data), false
+    case 'D':
+        return "OK", true
+    case 'k':
+        return "", true
+    case 'H':
+        return "OK", false // There is only one thread
+    case 'q', 'Q':
+        return client.query(data), false
+    }
+    return "", false
+}
+
+// query - The general query packets
+func (client *gdbConnection) query(data string) string {
+    switch {
+    case strings.HasPrefix(data, "qSupported"):
+        return "PacketSize=1000;QStartNoAckMode+;qXfer:features:read+"
+    case data == "QStartNoAckMode":
+        return "OK"
+    case data == "qAttached":
+        return "1"
+    case data == "qC":
+        return "QC1"
+    case data == "qfThreadInfo":
+        return "m1"
+    case data == "qsThreadInfo":
+        return "l"
+    case strings.HasPrefix(data, "qXfer:features:read:target.xml:"):
+        offset, length, ok := parseGDBRange(strings.TrimPrefix(data, "qXfer:features:read:target.xml:"))
+        if !ok {
+            return "E01"
+        }
+        if int(offset) >= len(GDBTARGETXML) {
+            return "l"
+        }
+        end := int(offset) + length
+        if end >= len(GDBTARGETXML) {
+            return "l" + GDBTARGETXML[offset:]
+        }
+        return "m" + GDBTARGETXML[offset:end]
+    }
+    return ""
+}
+
+// readRegisters - The registers in target.xml order, as hex
+func (client *gdbConnection) readRegisters() string {
+    registers := client.server.debugger.Registers()
+    text := ""
+    for _, value := range []uint16{
+        uint16(registers.A)<<8 | uint16(registers.F), uint16(registers.B)<<8 | uint16(registers.C),
+        uint16(registers.D)<<8 | uint16(registers.E), uint16(registers.H)<<8 | uint16(registers.L),
+        registers.SP, registers.PC,
+    } {
+        text += fmt.Sprintf("%02x%02x", uint8(value), uint8(value>>8)) // Little endian
+    }
+    return text
+}
+
+// writeRegisters - Sets the registers from the hex of a G packet
+func (client *gdbConnection) writeRegisters(data string) string {
+    bytes, err := hex.DecodeString(data)
+    if err != nil || len(bytes) != GDBREGISTERCOUNT*2 {
+        return "E01"
+    }
+    registers := client.server.debugger.Registers()
+    registers.F, registers.A = bytes[0], bytes[1]
+    registers.C, registers.B = bytes[2], bytes[3]
+    registers.E, registers.D = bytes[4], bytes[5]
+    registers.L, registers.H = bytes[6], bytes[7]
+    registers.SP = uint16(bytes[9])<<8 | uint16(bytes[8])
+    registers.PC = uint16(bytes[11])<<8 | uint16(bytes[10])
+    client.server.debugger.SetRegisters(registers)
+    return "OK"
+}
+
+// setStop - Z/z packets: type,address,kind
+func (client *gdbConnection) setStop(data string) string {
+    debugger := client.server.debugger
+    parts := strings.SplitN(data[1:], ",", 2)
+    address, length, ok := parseGDBRange(parts[len(parts)-1])
+    if len(parts) != 2 || !ok {
+        return "E01"
+    }
+    insert := data[0] == 'Z'
+
+    switch parts[0] {
+    case "0", "1": // Software and hardware breakpoints are the same thing here
+        id, exists := client.breakpoints[address]
+        if insert && !exists {
+            client.breakpoints[address] = debugger.SetBreakpoint(address)
+        } else if !insert && exists {
+            debugger.RemoveBreakpoint(id)
+            delete(client.breakpoints, address)
+        }
+        return "OK"
+    case "2", "3", "4":
+        kind := map[string]WatchKind{"2": WatchWrite, "3": WatchRead, "4": WatchAccess}[parts[0]]
+        if length == 0 {
+            return "E01"
+        }
+        end := int(address) + length - 1
+        if end > 0xFFFF { // Ranges past the end of the address space stop at 0xFFFF
+            end = 0xFFFF
+        }
+        key := fmt.Sprintf("%s,%04x,%x", parts[0], address, length)
+        id, exists := client.watchpoints[key]
+        if insert && !exists {
+            client.watchpoints[key] = debugger.AddWatchpoint(Watchpoint{Start: address, End: uint16(end), Kind: kind})
+        } else if !insert && exists {
+            debugger.RemoveWatchpoint(id)
+            delete(client.watchpoints, key)
+        }
+        return "OK"
+    }
+    return ""
+}
+
+// stopReply - The reply to s and c: SIGTRAP for steps, breakpoints and watchpoints,
+// SIGINT for Ctrl+C and SIGILL when the CPU hit an illegal opcode
+func (client *gdbConnection) stopReply(reason StopReason, err error) string {
+    switch reason {
+    case StopWatchpoint:
+        hit := client.server.debugger.WatchpointHits()[0]
+        name := "rwatch"
+        if hit.Watchpoint.Kind == WatchWrite {
+            name = "watch"
+        } else if hit.Watchpoint.Kind == WatchAccess {
+            name = "awatch"
+        }
+        return fmt.Sprintf("T05%s:%04x;", name, hit.Address)
+    case StopInterrupted:
+        return "S02"
+    case StopError:
+        return "S04"
+    }
+    return "S05"
+}
+
+// parseGDBRange - Parses "address,length" (both hex)
+func parseGDBRange(text string) (uint16, int, bool) {
+    parts := strings.SplitN(text, ",", 2)
+    if len(parts) != 2 {
+        return 0, 0, false
+    }
+    address, err := strconv.ParseUint(parts[0], 16, 16)
+    if err != nil {
+        return 0, 0, false
+    }
+    length, err := strconv.ParseUint(parts[1], 16, 16)
+    if err != nil {
+        return 0, 0, false
+    }
+    return uint16(address), int(length), true
+}
@@ -0,0 +1,154 @@
+package gmb
+
+import (
+    "bufio"
+    "fmt"
+    "net"
+    "strings"
+    "testing"
+    "time"
+)
+
+// gdbTestClient - Talks to a GDBServer the way GDB does
+type gdbTestClient struct {
+    t *testing.T
+    conn net.Conn
+    reader *bufio.Reader
+}
+
+// startGDB - Serves the machine of the debugger test program on a random local port
+func startGDB(t *testing.T, rom []uint8) (*GDBServer, *gdbTestClient) {
+    gb, err := New(rom)
+    if err != nil {
+        t.Fatal(err)
+    }
+    server, err := ListenGDB(gb, "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    go server.Serve()
+    conn, err := net.Dial("tcp", server.Addr().String())
+    if err != nil {
+        t.Fatal(err)
+    }
+    conn.SetDeadline(time.Now().Add(10 * time.Second))
+    return server, &gdbTestClient{t, conn, bufio.NewReader(conn)}
+}
+
+// command - Sends a packet and returns the reply
+func (client *gdbTestClient) command(data string) string {
+    client.t.Helper()
+    fmt.Fprintf(client.conn, "$%s#%02x", data, gdbChecksum(data))
+    if ack, err := client.reader.ReadByte(); err != nil || ack != '+' {
+        client.t.Fatalf("%s: expected an acknowledgement, got %q (%v)", data, ack, err)
+    }
+    return client.reply()
+}
+
+// reply - Reads one packet and checks its checksum
+func (client *gdbTestClient) reply() string {
+    client.t.Helper()
+    if _, err := client.reader.ReadString('$'); err != nil {
+        client.t.Fatal(err)
+    }
+    packet, err := client.reader.ReadString('#')
+    if err != nil {
+        client.t.Fatal(err)
+    }
+    packet = strings.TrimSuffix(packet, "#")
+    checksum := make([]byte, 2)
+    client.reader.Read(checksum[:1])
+    client.reader.Read(checksum[1:])
+    if string(checksum) != fmt.Sprintf("%02x", gdbChecksum(packet)) {
+        client.t.Errorf("Bad checksum on %q", packet)
+    }
+    return packet
+}
+
+func (client *gdbTestClient) expect(data string, expected string) {
+    client.t.Helper()
+    if reply := client.command(data); reply != expected {
+        client.t.Errorf("%s: expected %q, got %q", data, expected, reply)
+    }
+}
+
+func TestGDBRegistersAndMemory(t *testing.T) {
+    server, client := startGDB(t, debuggerMachineROM())
+    defer server.Close()
+    defer client.conn.Close()
+
+    client.expect("?", "S05")
+    client.expect("g", "000000000000000000000001") // PC=0100, little endian
+    client.expect("P1=3412", "OK")                  // BC
+    client.expect("p1", "3412")
+    client.expect("G"+"f005"+"3412"+"0000"+"10c0"+"feff"+"0001", "OK")
+    if registers := server.debugger.Registers(); registers.A != 0x05 || registers.F != 0xF0 || registers.H != 0xC0 || registers.SP != 0xFFFE {
+        t.Errorf("G did not write the registers: %s", registers)
+    }
+
+    client.expect("m100,3", "cd0002")
+    client.expect("Mc010,2:abcd", "OK")
+    client.expect("mc00f,4", "00abcd00")
+    client.expect("m100", "E01")
+    client.expect("vMustReplyEmpty", "")
+    if xml := client.command("qXfer:features:read:target.xml:0,1000"); !strings.HasPrefix(xml, "l<?xml") {
+        t.Errorf("Unexpected target description %q", xml)
+    }
+}
+
+func TestGDBExecution(t *testing.T) {
+    server, client := startGDB(t, debuggerMachineROM())
+    defer server.Close()
+    defer client.conn.Close()
+
+    client.expect("s", "S05")
+    client.expect("p5", "0002") // Into the CALL at 0200
+    client.expect("Z0,300,1", "OK")
+    client.expect("c", "S05")
+    client.expect("p5", "0003")
+    client.expect("z0,300,1", "OK")
+    client.expect("Z0,103,1", "OK")
+    client.expect("c", "S05")
+    client.expect("g", "000600000000000000000301") // INC A ran, back at 0103 with SP wrapped to 0000
+    client.expect("c", "S05")                      // The JR loop reaches the breakpoint again
+}
+
+func TestGDBWatchpointsAndInterrupt(t *testing.T) {
+    server, client := startGDB(t, watchpointMachineROM())
+    defer server.Close()
+    defer client.conn.Close()
+
+    client.expect("Z2,c0a0,10", "OK")
+    client.expect("c", "T05watch:c0a3;")
+    client.expect("z2,c0a0,10", "OK")
+    client.expect("Z3,c0a3,1", "OK")
+    client.expect("c", "T05rwatch:c0a3;")
+    client.expect("p5", "0b01")
+    client.expect("z3,c0a3,1", "OK")
+    client.expect("Z2,c0a3,0", "E01")
+    client.expect("Z2,fff0,20", "OK")
+    if watchpoints := server.debugger.Watchpoints(); len(watchpoints) != 1 || watchpoints[0].Start != 0xFFF0 || watchpoints[0].End != 0xFFFF {
+        t.Errorf("Ranges past 0xFFFF should end at 0xFFFF: %v", watchpoints)
+    }
+    client.expect("z2,fff0,20", "OK")
+
+    // The JR loop at 010B runs until GDB sends Ctrl+C
+    fmt.Fprintf(client.conn, "$c#%02x", gdbChecksum("c"))
+    client.reader.ReadByte()
+    stopped := make(chan bool)
+    go func() { // Repeated in case the first one arrives before c starts running
+        for {
+            select {
+            case <-stopped:
+                return
+            case <-time.After(10 * time.Millisecond):
+                client.conn.Write([]byte{0x03})
+            }
+        }
+    }()
+    reply := client.reply()
+    close(stopped)
+    if reply != "S02" {
+        t.Errorf("Expected SIGINT after Ctrl+C, got %q", reply)
+    }
+}
@@ -0,0 +1,176 @@
+package gmb
+
+import (
+    "errors"
+    "fmt"
+    "strings"
+)
+
+// CartridgeHeader - The information stored at 0x0100-0x014F of every cartridge
+// See: http://gbdev.gg8.se/wiki/articles/The_Cartridge_Header
+type CartridgeHeader struct {
+    Title string // 0x134-0x143 (shorter on newer cartridges which use the last bytes for other things)
+    ManufacturerCode string // 0x13F-0x142 on newer cartridges
+    CGBFlag uint8 // 0x143
+    NewLicenseeCode string // 0x144-0x145, only used when oldLicenseeCode is 0x33
+    SGBFlag uint8 // 0x146
+    CartridgeType uint8 // 0x147
+    ROMSizeCode uint8 // 0x148
+    RAMSizeCode uint8 // 0x149
+    DestinationCode uint8 // 0x14A
+    OldLicenseeCode uint8 // 0x14B
+    Version uint8 // 0x14C
+    HeaderChecksum uint8 // 0x14D
+    GlobalChecksum uint16 // 0x14E-0x14F, big endian
+
+    computedHeaderChecksum uint8
+    computedGlobalChecksum uint16
+}
+
+// errHeaderTooShort - The file is too small to contain a cartridge header
+var errHeaderTooShort = errors.New("file is too small to contain a cartridge header")
+
+// cartridgeTypeNames - Human readable names of the cartridge type byte (0x147)
+var cartridgeTypeNames = map[uint8]string{
+    0x00: "ROM ONLY", 0x01: "MBC1", 0x02: "MBC1+RAM", 0x03: "MBC1+RAM+BATTERY",
+    0x05: "MBC2", 0x06: "MBC2+BATTERY", 0x08: "ROM+RAM", 0x09: "ROM+RAM+BATTERY",
+    0x0B: "MMM01", 0x0C: "MMM01+RAM", 0x0D: "MMM01+RAM+BATTERY",
+    0x0F: "MBC3+TIMER+BATTERY", 0x10: "MBC3+TIMER+RAM+BATTERY", 0x11: "MBC3", 0x12: "MBC3+RAM", 0x13: "MBC3+RAM+BATTERY",
+    0x19: "MBC5", 0x1A: "MBC5+RAM", 0x1B: "MBC5+RAM+BATTERY",
+    0x1C: "MBC5+RUMBLE", 0x1D: "MBC5+RUMBLE+RAM", 0x1E: "MBC5+RUMBLE+RAM+BATTERY",
+    0x20: "MBC6", 0x22: "MBC7+SENSOR+RUMBLE+RAM+BATTERY",
+    0xFC: "POCKET CAMERA", 0xFD: "BANDAI TAMA5", 0xFE: "HuC3", 0xFF: "HuC1+RAM+BATTERY",
+}
+
+// ParseHeader - Reads the cartridge header out of a ROM image
+func ParseHeader(rom []uint8) (*CartridgeHeader, error) {
+    if len(rom) < 0x150 {
+        return nil, errHeaderTooShort
+    }
+
+    header := new(CartridgeHeader)
+    header.CGBFlag = rom[0x143]
+    if header.CGBFlag&0x80 != 0 { // CGB cartridges use the end of the title for the manufacturer code and CGB flag
+        header.Title = headerString(rom[0x134:0x13F])
+        header.ManufacturerCode = headerString(rom[0x13F:0x143])
+    } else {
+        header.Title = headerString(rom[0x134:0x144])
+    }
+    header.NewLicenseeCode = headerString(rom[0x144:0x146])
+    header.SGBFlag = rom[0x146]
+    header.CartridgeType = rom[0x147]
+    header.ROMSizeCode = rom[0x148]
+    header.RAMSizeCode = rom[0x149]
+    header.DestinationCode = rom[0x14A]
+    header.OldLicenseeCode = rom[0x14B]
+    header.Version = rom[0x14C]
+    header.HeaderChecksum = rom[0x14D]
+    header.GlobalChecksum = uint16(rom[0x14E])<<8 | uint16(rom[0x14F])
+
+    // x = 0 : FOR i = 0x0134 TO 0x014C : x = x - MEM[i] - 1 : NEXT
+    for i := 0x134; i <= 0x14C; i++ {
+        header.computedHeaderChecksum = header.computedHeaderChecksum - rom[i] - 1
+    }
+    // Sum of every byte in the ROM except for the two global checksum bytes
+    for i, value := range rom {
+        if i != 0x14E && i != 0x14F {
+            header.computedGlobalChecksum += uint16(value)
+        }
+    }
+    return header, nil
+}
+
+// headerString - Converts a zero padded ASCII field into a string
+func headerString(data []uint8) string {
+    return strings.TrimRight(string(data), "\x00 ")
+}
+
+// ValidateHeaderChecksum - The boot ROM refuses to run a cartridge whose header checksum is wrong
+func (header *CartridgeHeader) ValidateHeaderChecksum() error {
+    if header.HeaderChecksum != header.computedHeaderChecksum {
+        return fmt.Errorf("header checksum mismatch: header says %02X, computed %02X",
+            header.HeaderChecksum, header.computedHeaderChecksum)
+    }
+    return nil
+}
+
+// ValidateGlobalChecksum - The global checksum is never checked by the hardware, but a
+// mismatch is a good hint that the ROM was modified or is a bad dump
+func (header *CartridgeHeader) ValidateGlobalChecksum() error {
+    if header.GlobalChecksum != header.computedGlobalChecksum {
+        return fmt.Errorf("global checksum mismatch: header says %04X, computed %04X",
+            header.GlobalChecksum, header.computedGlobalChecksum)
+    }
+    return nil
+}
+
+// ROMSize - Size of the ROM in bytes as declared by the header (32KB << n)
+func (header *CartridgeHeader) ROMSize() int {
+    if header.ROMSizeCode > 0x08 {
+        return 0
+    }
+    return 32 * 1024 << header.ROMSizeCode
+}
+
+// RAMSize - Size of the external RAM in bytes as declared by the header
+// MBC2 cartridges declare 0 since their RAM is part of the controller
+func (header *CartridgeHeader) RAMSize() int {
+    sizes := map[uint8]int{0x00: 0, 0x01: 2 * 1024, 0x02: 8 * 1024, 0x03: 32 * 1024, 0x04: 128 * 1024, 0x05: 64 * 1024}
+    return sizes[header.RAMSizeCode]
+}
+
+// cartridgeTypeName - Human readable name of the cartridge type
+func (header *CartridgeHeader) cartridgeTypeName() string {
+    if name, ok := cartridgeTypeNames[header.CartridgeType]; ok {
+        return name
+    }
+    return "UNKNOWN"
+}
+
+// licensee - Returns the licensee code. Newer cartridges set the old code to 0x33
+// and store a two character code in the new licensee field instead
+func (header *CartridgeHeader) licensee() string {
+    if header.OldLicenseeCode == 0x33 {
+        return fmt.Sprintf("%q (new)", header.NewLicenseeCode)
+    }
+    return fmt.Sprintf("%02X (old)", header.OldLicenseeCode)
+}
+
+// String - Pretty prints the header for the info command
+func (header *CartridgeHeader) String() string {
+    cgb := "DMG only"
+    if header.CGBFlag == 0xC0 {
+        cgb = "CGB only"
+    } else if header.CGBFlag&0x80 != 0 {
+        cgb = "CGB enhanced"
+    }
+    sgb := "No"
+    if header.SGBFlag == 0x03 {
+        sgb = "Yes"
+    }
+    destination := "Japanese"
+    if header.DestinationCode == 0x01 {
+        destination = "Non-Japanese"
+    }
+    checksumStatus := func(err error) string {
+        if err != nil {
+            return "BAD (" + err.Error() + ")"
+        }
+        return "OK"
+    }
+
+    output := ""
+    output += fmt.Sprintf("Title            : %s\n", header.Title)
+    output += fmt.Sprintf("Manufacturer     : %s\n", header.ManufacturerCode)
+    output += fmt.Sprintf("CGB flag         : %02X (%s)\n", header.CGBFlag, cgb)
+    output += fmt.Sprintf("Licensee         : %s\n", header.licensee())
+    output += fmt.Sprintf("SGB support      : %s\n", sgb)
+    output += fmt.Sprintf("Cartridge type   : %02X (%s)\n", header.CartridgeType, header.cartridgeTypeName())
+    output += fmt.Sprintf("ROM size         : %02X (%dKB)\n", header.ROMSizeCode, header.ROMSize()/1024)
+    output += fmt.Sprintf("RAM size         : %02X (%dKB)\n", header.RAMSizeCode, header.RAMSize()/1024)
+    output += fmt.Sprintf("Destination      : %02X (%s)\n", header.DestinationCode, destination)
+    output += fmt.Sprintf("Version          : %02X\n", header.Version)
+    output += fmt.Sprintf("Header checksum  : %02X %s\n", header.HeaderChecksum, checksumStatus(header.ValidateHeaderChecksum()))
+    output += fmt.Sprintf("Global checksum  : %04X %s\n", header.GlobalChecksum, checksumStatus(header.ValidateGlobalChecksum()))
+    return output
+}
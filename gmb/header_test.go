@@ -0,0 +1,49 @@
+package gmb
+
+import "testing"
+
+func TestParseHeader(t *testing.T) {
+    rom := testROM(0x13, 64, 0x03)
+    copy(rom[0x134:], "POKEMON_GLDAAUE")
+    rom[0x143] = 0x80
+    rom[0x146] = 0x03
+    rom[0x148] = 0x05
+    rom[0x14B] = 0x33
+    copy(rom[0x144:], "01")
+
+    header, err := ParseHeader(rom)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if header.Title != "POKEMON_GLD" || header.ManufacturerCode != "AAUE" {
+        t.Errorf("Expected title POKEMON_GLD by AAUE, got %q by %q", header.Title, header.ManufacturerCode)
+    }
+    if header.ROMSize() != 1024*1024 || header.RAMSize() != 32*1024 {
+        t.Errorf("Expected 1MB of ROM and 32KB of RAM, got %d and %d", header.ROMSize(), header.RAMSize())
+    }
+    if header.cartridgeTypeName() != "MBC3+RAM+BATTERY" {
+        t.Errorf("Unexpected cartridge type %s", header.cartridgeTypeName())
+    }
+    if header.licensee() != `"01" (new)` {
+        t.Errorf("Unexpected licensee %s", header.licensee())
+    }
+    if header.ValidateHeaderChecksum() == nil {
+        t.Errorf("An all zero checksum should not validate")
+    }
+
+    rom[0x14D] = header.computedHeaderChecksum
+    header, _ = ParseHeader(rom)
+    sum := header.computedGlobalChecksum
+    rom[0x14E] = uint8(sum >> 8)
+    rom[0x14F] = uint8(sum)
+    header, _ = ParseHeader(rom)
+    if header.ValidateHeaderChecksum() != nil || header.ValidateGlobalChecksum() != nil {
+        t.Errorf("Checksums should validate: %v, %v", header.ValidateHeaderChecksum(), header.ValidateGlobalChecksum())
+    }
+}
+
+func TestParseHeaderTooShort(t *testing.T) {
+    if _, err := ParseHeader(make([]uint8, 0x100)); err == nil {
+        t.Errorf("Expected an error for a ROM without a header")
+    }
+}
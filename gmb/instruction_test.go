@@ -1,13 +1,11 @@
-package main
+package gmb
 
 import "testing"
 
 func testCPU() *CPU {
     cpu := newCPU()
 
-    emptyMemory := make([]uint8, 65536) // Make sure that we have a full 64KB of memory
-    cart := new(Cartridge)
-    cart.memory = emptyMemory
+    cart, _ := newCartridge(make([]uint8, 0x8000)) // An empty 32KB ROM
     cpu.mmu.cart = cart
     return cpu
 }
@@ -92,3 +90,61 @@ func TestPush(t *testing.T) {
         t.Errorf("PUSH HL: Program counter was not properly incremented (+1)")
     }
 }
+
+func TestIllegalOpcode(t *testing.T) {
+    cpu := testCPU()
+    cpu.programCounter = 0x100
+    cpu.mmu.write8(0x100, 0xDD)
+
+    _, err := cpu.step()
+    illegal, ok := err.(*ErrIllegalOpcode)
+    if !ok {
+        t.Fatalf("Expected ErrIllegalOpcode, got %v", err)
+    }
+    if illegal.PC != 0x100 || illegal.Opcode != 0xDD {
+        t.Errorf("Expected opcode DD at 0100, got %02X at %04X", illegal.Opcode, illegal.PC)
+    }
+    if cpu.programCounter != 0x100 {
+        t.Errorf("PC should not move past an illegal opcode")
+    }
+}
+
+func TestIllegalOpcodeAfterHaltBug(t *testing.T) {
+    cpu := testCPU()
+    cpu.programCounter = 0x100
+    cpu.mmu.write8(0x100, 0x76) // HALT
+    cpu.mmu.write8(0x101, 0xDD)
+    cpu.mmu.write8(0xFFFF, 0x01)
+    cpu.mmu.setIF(0x01) // V-Blank is pending but interrupts are disabled
+
+    cpu.step()
+    _, err := cpu.step()
+    illegal, ok := err.(*ErrIllegalOpcode)
+    if !ok {
+        t.Fatalf("Expected ErrIllegalOpcode, got %v", err)
+    }
+    if illegal.PC != 0x101 || illegal.Opcode != 0xDD {
+        t.Errorf("Expected opcode DD at 0101, got %02X at %04X", illegal.Opcode, illegal.PC)
+    }
+}
+
+func TestHaltBug(t *testing.T) {
+    cpu := testCPU()
+    cpu.programCounter = 0x100
+    cpu.mmu.write8(0x100, 0x76) // HALT
+    cpu.mmu.write8(0x101, 0x3C) // INC A
+    cpu.mmu.write8(0xFFFF, 0x01)
+    cpu.mmu.setIF(0x01) // V-Blank is pending but interrupts are disabled
+
+    for i := 0; i < 3; i++ {
+        if _, err := cpu.step(); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if cpu.halted {
+        t.Errorf("HALT bug: CPU should not halt")
+    }
+    if cpu.ra != 2 || cpu.programCounter != 0x102 {
+        t.Errorf("HALT bug: INC A should run twice, A=%d PC=%04X", cpu.ra, cpu.programCounter)
+    }
+}
@@ -0,0 +1,69 @@
+package gmb
+
+// Buttons - The state of the eight Game Boy buttons. true means the button is held down
+type Buttons struct {
+    A, B, Select, Start bool
+    Right, Left, Up, Down bool
+}
+
+// P1 (0xFF00) - The buttons are wired as a 2x4 matrix. The game selects a row by writing
+// a 0 to bit 4 or 5 and then reads the four columns from the low bits, where 0 means pressed
+//
+//   Bit 7-6  Unused (read as 1)
+//   Bit 5    0 = Select the action buttons
+//   Bit 4    0 = Select the direction buttons
+//   Bit 3    Down  or Start
+//   Bit 2    Up    or Select
+//   Bit 1    Left  or B
+//   Bit 0    Right or A
+
+// readP1 - Returns the P1 register for the currently held buttons
+func (mmu *MMU) readP1() uint8 {
+    return 0xC0 | (mmu.internalRAM[0xFF00] & 0x30) | mmu.joypadLines()
+}
+
+// writeP1 - Only the select bits can be written
+func (mmu *MMU) writeP1(data uint8) {
+    before := mmu.joypadLines()
+    mmu.internalRAM[0xFF00] = data & 0x30
+    mmu.checkJoypadInterrupt(before)
+}
+
+// setButtons - Changes the held buttons and requests the joypad interrupt if one was pressed
+func (mmu *MMU) setButtons(buttons Buttons) {
+    before := mmu.joypadLines()
+    mmu.buttons = buttons
+    mmu.checkJoypadInterrupt(before)
+}
+
+// joypadLines - Returns bits 0-3 of P1 for the selected row(s) of buttons
+func (mmu *MMU) joypadLines() uint8 {
+    selected := mmu.internalRAM[0xFF00]
+    lines := uint8(0x0F)
+    if selected & 0x10 == 0 {
+        lines &= ^buttonBits(mmu.buttons.Right, mmu.buttons.Left, mmu.buttons.Up, mmu.buttons.Down)
+    }
+    if selected & 0x20 == 0 {
+        lines &= ^buttonBits(mmu.buttons.A, mmu.buttons.B, mmu.buttons.Select, mmu.buttons.Start)
+    }
+    return lines
+}
+
+// checkJoypadInterrupt - Requests the joypad interrupt (IF bit 4) when any of the
+// P1 input lines went from high to low
+func (mmu *MMU) checkJoypadInterrupt(before uint8) {
+    if before & ^mmu.joypadLines() != 0 {
+        mmu.setIF(mmu.getIF() | 0x10)
+    }
+}
+
+// buttonBits - Packs four buttons into the low bits of a byte; 1 means the button is held down
+func buttonBits(bit0 bool, bit1 bool, bit2 bool, bit3 bool) uint8 {
+    bits := uint8(0)
+    for i, held := range []bool{bit0, bit1, bit2, bit3} {
+        if held {
+            bits |= 1 << uint(i)
+        }
+    }
+    return bits
+}
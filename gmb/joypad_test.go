@@ -0,0 +1,52 @@
+package gmb
+
+import "testing"
+
+func TestJoypadRegister(t *testing.T) {
+    cpu := testCPU()
+    mmu := cpu.mmu
+    if mmu.read8(0xFF00) != 0xFF {
+        t.Errorf("P1 should read 0xFF with no row selected, got %02X", mmu.read8(0xFF00))
+    }
+
+    mmu.setButtons(Buttons{A: true, Start: true, Left: true})
+    mmu.write8(0xFF00, 0x20) // Directions
+    if mmu.read8(0xFF00) != 0xED {
+        t.Errorf("Directions read %02X, expected ED", mmu.read8(0xFF00))
+    }
+    mmu.write8(0xFF00, 0x10) // Actions
+    if mmu.read8(0xFF00) != 0xD6 {
+        t.Errorf("Actions read %02X, expected D6", mmu.read8(0xFF00))
+    }
+}
+
+func TestJoypadInterrupt(t *testing.T) {
+    cpu := testCPU()
+    mmu := cpu.mmu
+    mmu.write8(0xFF00, 0x20)
+
+    mmu.setButtons(Buttons{A: true})
+    if mmu.getIF() & 0x10 != 0 {
+        t.Errorf("Buttons in the unselected row should not request an interrupt")
+    }
+    mmu.setButtons(Buttons{A: true, Down: true})
+    if mmu.getIF() & 0x10 == 0 {
+        t.Errorf("Pressing a selected button should request the joypad interrupt")
+    }
+
+    mmu.setIF(0)
+    mmu.setButtons(Buttons{A: true})
+    if mmu.getIF() & 0x10 != 0 {
+        t.Errorf("Releasing a button should not request an interrupt")
+    }
+
+    // The interrupt is serviced at 0x0060
+    mmu.setButtons(Buttons{A: true, Down: true})
+    mmu.write8(0xFFFF, 0x10)
+    cpu.inte = true
+    cpu.stackPointer = 0xFFFE
+    cpu.checkForInterrupts()
+    if cpu.programCounter != 0x60 || mmu.getIF() & 0x10 != 0 {
+        t.Errorf("Joypad interrupt was not serviced: PC=%04X IF=%02X", cpu.programCounter, mmu.getIF())
+    }
+}
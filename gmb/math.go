@@ -1,4 +1,4 @@
-package main
+package gmb
 
 // Sub : Subtracts B from A and then sets micro controller flags
 // the borrow argument is used by SBC, everything else should call it with a value of 0
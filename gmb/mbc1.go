@@ -0,0 +1,81 @@
+package gmb
+
+// MBC1 - The first memory bank controller. Supports up to 2MB of ROM and 32KB of RAM
+//
+//   0x0000-0x1FFF  (W) RAM enable: 0xA in the lower nibble enables external RAM
+//   0x2000-0x3FFF  (W) ROM bank number, lower 5 bits. Bank 0 is treated as bank 1
+//   0x4000-0x5FFF  (W) RAM bank number or upper 2 bits of the ROM bank number
+//   0x6000-0x7FFF  (W) Banking mode select: 0 = simple, 1 = advanced
+//   0xA000-0xBFFF  (RW) External RAM bank
+//
+// In the advanced banking mode the upper bits also switch the bank mapped into
+// 0x0000-0x3FFF (on large ROMs) and select the RAM bank
+type MBC1 struct {
+    rom []uint8
+    ram []uint8
+    ramEnabled bool
+    romBank uint8 // 5-bit BANK1 register
+    upperBits uint8 // 2-bit BANK2 register
+    bankingMode uint8
+}
+
+// ramOffset - Returns the offset into the external RAM for the given address
+func (mbc *MBC1) ramOffset(address uint16) int {
+    bank := 0
+    if mbc.bankingMode == 1 {
+        bank = int(mbc.upperBits)
+    }
+    return (bank*0x2000 + int(address-0xA000)) % len(mbc.ram)
+}
+
+func (mbc *MBC1) read8(address uint16) uint8 {
+    switch {
+    case address < 0x4000:
+        bank := 0
+        if mbc.bankingMode == 1 {
+            bank = int(mbc.upperBits) << 5
+        }
+        return readROMBank(mbc.rom, bank, address)
+    case address < 0x8000:
+        bank := int(mbc.upperBits)<<5 | int(mbc.romBank)
+        return readROMBank(mbc.rom, bank, address)
+    case address >= 0xA000 && address <= 0xBFFF:
+        if !mbc.ramEnabled || len(mbc.ram) == 0 {
+            return 0xFF // Open bus
+        }
+        return mbc.ram[mbc.ramOffset(address)]
+    }
+    return 0xFF
+}
+
+func (mbc *MBC1) currentROMBank() int {
+    return (int(mbc.upperBits)<<5 | int(mbc.romBank)) % romBankCount(mbc.rom)
+}
+
+func (mbc *MBC1) write8(address uint16, data uint8) {
+    switch {
+    case address < 0x2000:
+        mbc.ramEnabled = (data & 0xF) == 0xA
+    case address < 0x4000:
+        mbc.romBank = data & 0x1F
+        if mbc.romBank == 0 { // Bank 0 can never be selected into 0x4000-0x7FFF
+            mbc.romBank = 1
+        }
+    case address < 0x6000:
+        mbc.upperBits = data & 0x3
+    case address < 0x8000:
+        mbc.bankingMode = data & 0x1
+    case address >= 0xA000 && address <= 0xBFFF:
+        if mbc.ramEnabled && len(mbc.ram) > 0 {
+            mbc.ram[mbc.ramOffset(address)] = data
+        }
+    }
+}
+
+func newMBC1(rom []uint8, ramSize int) *MBC1 {
+    mbc := new(MBC1)
+    mbc.rom = rom
+    mbc.ram = make([]uint8, ramSize)
+    mbc.romBank = 1
+    return mbc
+}
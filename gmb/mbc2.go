@@ -0,0 +1,58 @@
+package gmb
+
+// MBC2 - Supports up to 256KB of ROM (16 banks) and has 512x4 bits of RAM built in
+//
+//   0x0000-0x3FFF  (W) Address bit 8 clear: RAM enable (0xA in the lower nibble)
+//                      Address bit 8 set:   ROM bank number, 4 bits. Bank 0 is treated as bank 1
+//   0xA000-0xA1FF  (RW) Built-in RAM. Only the lower 4 bits of each byte are used
+//   0xA200-0xBFFF  (RW) Echoes of 0xA000-0xA1FF
+type MBC2 struct {
+    rom []uint8
+    ram [512]uint8
+    ramEnabled bool
+    romBank uint8
+}
+
+func (mbc *MBC2) read8(address uint16) uint8 {
+    switch {
+    case address < 0x4000:
+        return readROMBank(mbc.rom, 0, address)
+    case address < 0x8000:
+        return readROMBank(mbc.rom, int(mbc.romBank), address)
+    case address >= 0xA000 && address <= 0xBFFF:
+        if !mbc.ramEnabled {
+            return 0xFF // Open bus
+        }
+        return mbc.ram[address&0x1FF] | 0xF0 // The upper nibble is not connected and reads back as 1s
+    }
+    return 0xFF
+}
+
+func (mbc *MBC2) currentROMBank() int {
+    return int(mbc.romBank) % romBankCount(mbc.rom)
+}
+
+func (mbc *MBC2) write8(address uint16, data uint8) {
+    switch {
+    case address < 0x4000:
+        if (address & 0x100) == 0 {
+            mbc.ramEnabled = (data & 0xF) == 0xA
+        } else {
+            mbc.romBank = data & 0xF
+            if mbc.romBank == 0 {
+                mbc.romBank = 1
+            }
+        }
+    case address >= 0xA000 && address <= 0xBFFF:
+        if mbc.ramEnabled {
+            mbc.ram[address&0x1FF] = data & 0x0F
+        }
+    }
+}
+
+func newMBC2(rom []uint8) *MBC2 {
+    mbc := new(MBC2)
+    mbc.rom = rom
+    mbc.romBank = 1
+    return mbc
+}
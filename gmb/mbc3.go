@@ -0,0 +1,90 @@
+package gmb
+
+// MBC3 - Supports up to 2MB of ROM (128 banks), 32KB of RAM (4 banks) and an optional real time clock
+//
+//   0x0000-0x1FFF  (W) RAM and RTC enable: 0xA in the lower nibble enables both
+//   0x2000-0x3FFF  (W) ROM bank number, 7 bits. Bank 0 is treated as bank 1
+//   0x4000-0x5FFF  (W) 0x00-0x03 maps a RAM bank, 0x08-0x0C maps an RTC register into 0xA000-0xBFFF
+//   0x6000-0x7FFF  (W) Latch clock data (0x00 then 0x01)
+//   0xA000-0xBFFF  (RW) External RAM bank or RTC register
+type MBC3 struct {
+    rom []uint8
+    ram []uint8
+    rtc *RealTimeClock // nil if the cartridge has no timer
+    ramEnabled bool
+    romBank uint8
+    ramBank uint8 // RAM bank (0x00-0x03) or RTC register (0x08-0x0C)
+}
+
+func (mbc *MBC3) read8(address uint16) uint8 {
+    switch {
+    case address < 0x4000:
+        return readROMBank(mbc.rom, 0, address)
+    case address < 0x8000:
+        return readROMBank(mbc.rom, int(mbc.romBank), address)
+    case address >= 0xA000 && address <= 0xBFFF:
+        if !mbc.ramEnabled {
+            return 0xFF // Open bus
+        }
+        if mbc.ramBank >= 0x08 && mbc.ramBank <= 0x0C {
+            if mbc.rtc == nil {
+                return 0xFF
+            }
+            return mbc.rtc.read(mbc.ramBank)
+        }
+        if mbc.ramBank <= 0x03 && len(mbc.ram) > 0 {
+            return mbc.ram[mbc.ramOffset(address)]
+        }
+    }
+    return 0xFF
+}
+
+func (mbc *MBC3) currentROMBank() int {
+    return int(mbc.romBank) % romBankCount(mbc.rom)
+}
+
+func (mbc *MBC3) write8(address uint16, data uint8) {
+    switch {
+    case address < 0x2000:
+        mbc.ramEnabled = (data & 0xF) == 0xA
+    case address < 0x4000:
+        mbc.romBank = data & 0x7F
+        if mbc.romBank == 0 {
+            mbc.romBank = 1
+        }
+    case address < 0x6000:
+        mbc.ramBank = data
+    case address < 0x8000:
+        if mbc.rtc != nil {
+            mbc.rtc.writeLatch(data)
+        }
+    case address >= 0xA000 && address <= 0xBFFF:
+        if !mbc.ramEnabled {
+            return
+        }
+        if mbc.ramBank >= 0x08 && mbc.ramBank <= 0x0C {
+            if mbc.rtc != nil {
+                mbc.rtc.write(mbc.ramBank, data)
+            }
+        } else if mbc.ramBank <= 0x03 && len(mbc.ram) > 0 {
+            mbc.ram[mbc.ramOffset(address)] = data
+        }
+    }
+}
+
+// ramOffset - Returns the offset into the external RAM for the given address
+func (mbc *MBC3) ramOffset(address uint16) int {
+    return (int(mbc.ramBank)*0x2000 + int(address-0xA000)) % len(mbc.ram)
+}
+
+// newMBC3 - Creates an MBC3 controller. The clock is only used if the cartridge has a timer
+func newMBC3(rom []uint8, ramSize int, hasTimer bool, clock ClockSource) *MBC3 {
+    mbc := new(MBC3)
+    mbc.rom = rom
+    mbc.ram = make([]uint8, ramSize)
+    mbc.romBank = 1
+    if hasTimer {
+        mbc.rtc = newRealTimeClock(clock)
+    }
+    return mbc
+}
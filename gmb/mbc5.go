@@ -0,0 +1,92 @@
+package gmb
+
+// RumbleCallback - Called whenever the rumble motor of a cartridge is switched on or off
+type RumbleCallback func(on bool)
+
+// MBC5 - Supports up to 8MB of ROM (512 banks) and 128KB of RAM (16 banks)
+//
+//   0x0000-0x1FFF  (W) RAM enable: 0xA in the lower nibble enables external RAM
+//   0x2000-0x2FFF  (W) Lower 8 bits of the ROM bank number. Bank 0 can be selected
+//   0x3000-0x3FFF  (W) Bit 8 (the ninth bit) of the ROM bank number
+//   0x4000-0x5FFF  (W) RAM bank number (0x00-0x0F)
+//   0xA000-0xBFFF  (RW) External RAM bank
+//
+// Rumble cartridges wire bit 3 of the RAM bank register to the motor instead,
+// leaving them with only 8 RAM banks
+type MBC5 struct {
+    rom []uint8
+    ram []uint8
+    ramEnabled bool
+    romBank uint16 // 9-bit ROM bank number
+    ramBank uint8
+
+    hasRumble bool
+    rumbleOn bool
+    rumble RumbleCallback // May be nil
+}
+
+func (mbc *MBC5) read8(address uint16) uint8 {
+    switch {
+    case address < 0x4000:
+        return readROMBank(mbc.rom, 0, address)
+    case address < 0x8000:
+        return readROMBank(mbc.rom, int(mbc.romBank), address)
+    case address >= 0xA000 && address <= 0xBFFF:
+        if !mbc.ramEnabled || len(mbc.ram) == 0 {
+            return 0xFF // Open bus
+        }
+        return mbc.ram[mbc.ramOffset(address)]
+    }
+    return 0xFF
+}
+
+func (mbc *MBC5) currentROMBank() int {
+    return int(mbc.romBank) % romBankCount(mbc.rom)
+}
+
+func (mbc *MBC5) write8(address uint16, data uint8) {
+    switch {
+    case address < 0x2000:
+        mbc.ramEnabled = (data & 0xF) == 0xA
+    case address < 0x3000:
+        mbc.romBank = (mbc.romBank & 0x100) | uint16(data)
+    case address < 0x4000:
+        mbc.romBank = (mbc.romBank & 0xFF) | (uint16(data&0x1) << 8)
+    case address < 0x6000:
+        if mbc.hasRumble {
+            mbc.ramBank = data & 0x7
+            mbc.setRumble((data & 0x8) != 0)
+        } else {
+            mbc.ramBank = data & 0xF
+        }
+    case address >= 0xA000 && address <= 0xBFFF:
+        if mbc.ramEnabled && len(mbc.ram) > 0 {
+            mbc.ram[mbc.ramOffset(address)] = data
+        }
+    }
+}
+
+// setRumble - Switches the motor and notifies the callback when its state changes
+func (mbc *MBC5) setRumble(on bool) {
+    if on == mbc.rumbleOn {
+        return
+    }
+    mbc.rumbleOn = on
+    if mbc.rumble != nil {
+        mbc.rumble(on)
+    }
+}
+
+// ramOffset - Returns the offset into the external RAM for the given address
+func (mbc *MBC5) ramOffset(address uint16) int {
+    return (int(mbc.ramBank)*0x2000 + int(address-0xA000)) % len(mbc.ram)
+}
+
+func newMBC5(rom []uint8, ramSize int, hasRumble bool) *MBC5 {
+    mbc := new(MBC5)
+    mbc.rom = rom
+    mbc.ram = make([]uint8, ramSize)
+    mbc.romBank = 1
+    mbc.hasRumble = hasRumble
+    return mbc
+}
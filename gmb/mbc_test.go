@@ -0,0 +1,221 @@
+package gmb
+
+import (
+    "testing"
+    "time"
+)
+
+// testROM - Builds a ROM image of the given number of 16KB banks where the
+// first byte of every bank holds the bank number
+func testROM(cartridgeType uint8, banks int, ramSize uint8) []uint8 {
+    rom := make([]uint8, banks*0x4000)
+    for bank := 0; bank < banks; bank++ {
+        rom[bank*0x4000] = uint8(bank)
+    }
+    rom[0x147] = cartridgeType
+    rom[0x149] = ramSize
+    return rom
+}
+
+// testCartridge - Builds a cartridge around a testROM
+func testCartridge(t *testing.T, cartridgeType uint8, banks int, ramSize uint8) *Cartridge {
+    cart, err := newCartridge(testROM(cartridgeType, banks, ramSize))
+    if err != nil {
+        t.Fatal(err)
+    }
+    return cart
+}
+
+func TestUnsupportedMapper(t *testing.T) {
+    _, err := newCartridge(testROM(0x22, 4, 0x00)) // MBC7
+    if _, ok := err.(*ErrUnsupportedMapper); !ok {
+        t.Errorf("Expected ErrUnsupportedMapper, got %v", err)
+    }
+}
+
+func TestMBC1ROMBanking(t *testing.T) {
+    cart := testCartridge(t, 0x01, 128, 0x00)
+
+    if cart.read8(0x4000) != 1 {
+        t.Errorf("MBC1: Bank 1 should be mapped at startup, got %d", cart.read8(0x4000))
+    }
+
+    cart.write8(0x2000, 0x00)
+    if cart.read8(0x4000) != 1 {
+        t.Errorf("MBC1: Selecting bank 0 should select bank 1, got %d", cart.read8(0x4000))
+    }
+
+    cart.write8(0x2000, 0x05)
+    cart.write8(0x4000, 0x02)
+    if cart.read8(0x4000) != 0x45 {
+        t.Errorf("MBC1: Expected bank 0x45, got %X", cart.read8(0x4000))
+    }
+
+    if cart.read8(0x0000) != 0 {
+        t.Errorf("MBC1: Bank 0 should be mapped to 0x0000 in simple banking mode")
+    }
+    cart.write8(0x6000, 0x01)
+    if cart.read8(0x0000) != 0x40 {
+        t.Errorf("MBC1: Expected bank 0x40 at 0x0000 in advanced banking mode, got %X", cart.read8(0x0000))
+    }
+}
+
+func TestMBC1RAMBanking(t *testing.T) {
+    cart := testCartridge(t, 0x03, 4, 0x03)
+
+    cart.write8(0xA000, 0x12)
+    if cart.read8(0xA000) != 0xFF {
+        t.Errorf("MBC1: Disabled RAM should read 0xFF")
+    }
+
+    cart.write8(0x0000, 0x0A)
+    cart.write8(0x6000, 0x01)
+    cart.write8(0x4000, 0x00)
+    cart.write8(0xA000, 0x12)
+    cart.write8(0x4000, 0x01)
+    cart.write8(0xA000, 0x34)
+
+    cart.write8(0x4000, 0x00)
+    if cart.read8(0xA000) != 0x12 {
+        t.Errorf("MBC1: RAM bank 0 was not preserved, got %X", cart.read8(0xA000))
+    }
+    cart.write8(0x4000, 0x01)
+    if cart.read8(0xA000) != 0x34 {
+        t.Errorf("MBC1: RAM bank 1 was not preserved, got %X", cart.read8(0xA000))
+    }
+}
+
+func TestMBC2(t *testing.T) {
+    cart := testCartridge(t, 0x06, 16, 0x00)
+
+    cart.write8(0x2100, 0x0F)
+    if cart.read8(0x4000) != 0x0F {
+        t.Errorf("MBC2: Expected bank 0xF, got %X", cart.read8(0x4000))
+    }
+    cart.write8(0x2000, 0x03) // Bit 8 is clear, so this goes to the RAM enable register
+    if cart.read8(0x4000) != 0x0F {
+        t.Errorf("MBC2: Writes with address bit 8 clear should not switch ROM banks")
+    }
+
+    cart.write8(0x0000, 0x0A)
+    cart.write8(0xA010, 0x5A)
+    if cart.read8(0xA010) != 0xFA {
+        t.Errorf("MBC2: Expected the upper nibble to read back as 1s, got %X", cart.read8(0xA010))
+    }
+    if cart.read8(0xA210) != 0xFA || cart.read8(0xBE10) != 0xFA {
+        t.Errorf("MBC2: Built-in RAM is not echoed across 0xA000-0xBFFF")
+    }
+}
+
+func TestMBC3ROMBanking(t *testing.T) {
+    cart := testCartridge(t, 0x13, 128, 0x03)
+
+    cart.write8(0x2000, 0x7F)
+    if cart.read8(0x4000) != 0x7F {
+        t.Errorf("MBC3: Expected bank 0x7F, got %X", cart.read8(0x4000))
+    }
+
+    cart.write8(0x0000, 0x0A)
+    for bank := uint8(0); bank < 4; bank++ {
+        cart.write8(0x4000, bank)
+        cart.write8(0xBFFF, 0x10+bank)
+    }
+    for bank := uint8(0); bank < 4; bank++ {
+        cart.write8(0x4000, bank)
+        if cart.read8(0xBFFF) != 0x10+bank {
+            t.Errorf("MBC3: RAM bank %d was not preserved, got %X", bank, cart.read8(0xBFFF))
+        }
+    }
+}
+
+// fakeClock - A clock which only moves when told to
+type fakeClock struct {
+    now time.Time
+}
+
+func (clock *fakeClock) time() time.Time {
+    return clock.now
+}
+
+func TestMBC3RealTimeClock(t *testing.T) {
+    clock := &fakeClock{time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)}
+    mbc := newMBC3(testROM(0x10, 4, 0x03), 0x8000, true, clock.time)
+
+    readRegister := func(register uint8) uint8 {
+        mbc.write8(0x4000, register)
+        return mbc.read8(0xA000)
+    }
+    latch := func() {
+        mbc.write8(0x6000, 0x00)
+        mbc.write8(0x6000, 0x01)
+    }
+
+    mbc.write8(0x0000, 0x0A)
+    clock.now = clock.now.Add(1*time.Hour + 2*time.Minute + 3*time.Second)
+    if readRegister(0x08) != 0 {
+        t.Errorf("RTC: Registers should not change until they are latched")
+    }
+
+    latch()
+    if readRegister(0x08) != 3 || readRegister(0x09) != 2 || readRegister(0x0A) != 1 {
+        t.Errorf("RTC: Expected 01:02:03, got %02d:%02d:%02d", readRegister(0x0A), readRegister(0x09), readRegister(0x08))
+    }
+
+    // Halt the clock; time passing should have no effect
+    mbc.write8(0x4000, 0x0C)
+    mbc.write8(0xA000, 0x40)
+    clock.now = clock.now.Add(10 * time.Minute)
+    latch()
+    if readRegister(0x09) != 2 {
+        t.Errorf("RTC: Clock kept running while halted")
+    }
+
+    // Restart and run past day 511 to set the carry bit
+    mbc.write8(0x4000, 0x0C)
+    mbc.write8(0xA000, 0x00)
+    clock.now = clock.now.Add(512 * 24 * time.Hour)
+    latch()
+    if readRegister(0x0C)&0x80 == 0 {
+        t.Errorf("RTC: Day counter carry was not set")
+    }
+    if readRegister(0x0B) != 0 || readRegister(0x0C)&0x1 != 0 {
+        t.Errorf("RTC: Day counter did not wrap around")
+    }
+}
+
+func TestMBC5ROMBanking(t *testing.T) {
+    cart := testCartridge(t, 0x19, 512, 0x00)
+
+    cart.write8(0x2000, 0x00)
+    if cart.read8(0x4000) != 0 {
+        t.Errorf("MBC5: Bank 0 should be selectable, got %d", cart.read8(0x4000))
+    }
+
+    cart.write8(0x2000, 0x23)
+    cart.write8(0x3000, 0x01)
+    if cart.read8(0x4000) != 0x23 || cart.read8(0x4001) != 0x00 {
+        t.Errorf("MBC5: Expected bank 0x123")
+    }
+    if cart.mbc.(*MBC5).romBank != 0x123 {
+        t.Errorf("MBC5: Expected ROM bank register 0x123, got %X", cart.mbc.(*MBC5).romBank)
+    }
+}
+
+func TestMBC5Rumble(t *testing.T) {
+    cart := testCartridge(t, 0x1E, 4, 0x04)
+    changes := []bool{}
+    cart.setRumbleCallback(func(on bool) { changes = append(changes, on) })
+
+    cart.write8(0x0000, 0x0A)
+    cart.write8(0x4000, 0x09) // Motor on, RAM bank 1
+    cart.write8(0xA000, 0x55)
+    cart.write8(0x4000, 0x09)
+    cart.write8(0x4000, 0x01) // Motor off, RAM bank 1
+
+    if len(changes) != 2 || !changes[0] || changes[1] {
+        t.Errorf("MBC5: Expected the motor to switch on and then off, got %v", changes)
+    }
+    if cart.read8(0xA000) != 0x55 {
+        t.Errorf("MBC5: The rumble bit should not change the RAM bank")
+    }
+}
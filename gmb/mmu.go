@@ -0,0 +1,441 @@
+package gmb
+
+import (
+    "io"
+    //"github.com/hajimehoshi/ebiten/ebitenutil"
+)
+
+// MMU - Memory management unit. Exposes a read/write interface to some internal memory
+//
+//   0x0000-0x7FFF  Cartridge ROM
+//   0x8000-0x9FFF  Video RAM (VRAM)
+//   0xA000-0xBFFF  Cartridge RAM
+//   0xC000-0xDFFF  Work RAM (WRAM)
+//   0xE000-0xFDFF  Echo RAM; mirrors 0xC000-0xDDFF
+//   0xFE00-0xFE9F  Object attribute memory (OAM)
+//   0xFEA0-0xFEFF  Unusable
+//   0xFF00-0xFF7F  I/O registers
+//   0xFF80-0xFFFE  High RAM (HRAM)
+//   0xFFFF         Interrupt enable register
+type MMU struct {
+    internalRAM []uint8 // I/O registers, HRAM and IE (0xFF00-0xFFFF)
+    vram [0x2000]uint8
+    wram [0x2000]uint8
+    oam [0xA0]uint8
+    dma DMA
+    apu *APU
+    cart *Cartridge
+    statMode uint8
+    statLine bool // The internal STAT interrupt line; the interrupt is requested when it goes high
+    buttons Buttons // Set by the frontend through GameBoy.SetButtons
+    serialOutput io.Writer // Receives every byte written to the serial port. May be nil
+    watchpoints []Watchpoint // Installed by the debugger; nil when there are none
+    watchpointHits []WatchpointHit // Accesses which matched a watchpoint, until the debugger takes them
+}
+
+// readMemory - Reads from the memory regions below the I/O registers
+func (mmu *MMU) readMemory(address uint16) uint8 {
+    switch {
+    case address < 0x8000:
+        return mmu.cart.read8(address)
+    case address < 0xA000:
+        return mmu.vram[address-0x8000]
+    case address < 0xC000:
+        return mmu.cart.read8(address)
+    case address < 0xE000:
+        return mmu.wram[address-0xC000]
+    case address < 0xFE00:
+        return mmu.wram[address-0xE000] // Echo RAM
+    case address < 0xFEA0:
+        return mmu.oam[address-0xFE00]
+    }
+
+    // Unusable region. On the DMG this reads 0xFF while the PPU is using OAM and 0x00 otherwise
+    if mmu.showDisplay() && (mmu.statMode == 0x2 || mmu.statMode == 0x3) {
+        return 0xFF
+    }
+    return 0x00
+}
+
+// writeMemory - Writes to the memory regions below the I/O registers
+func (mmu *MMU) writeMemory(address uint16, data uint8) {
+    switch {
+    case address < 0x8000:
+        mmu.cart.write8(address, data) // Memory bank controller registers
+    case address < 0xA000:
+        mmu.vram[address-0x8000] = data
+    case address < 0xC000:
+        mmu.cart.write8(address, data)
+    case address < 0xE000:
+        mmu.wram[address-0xC000] = data
+    case address < 0xFE00:
+        mmu.wram[address-0xE000] = data // Echo RAM
+    case address < 0xFEA0:
+        mmu.oam[address-0xFE00] = data
+    }
+    // Writes to the unusable region are ignored
+}
+
+// peek - Reads a byte for the debugger: never blocked by DMA and never noticed by watchpoints
+func (mmu *MMU) peek(address uint16) uint8 {
+    if address < 0xFF00 {
+        return mmu.readMemory(address)
+    }
+    return mmu.readBus(address)
+}
+
+// poke - Writes a byte for the debugger, bypassing DMA. Writes to the ROM area still reach
+// the memory bank controller, as they would on the real hardware
+func (mmu *MMU) poke(address uint16, data uint8) {
+    if address < 0xFF00 {
+        mmu.writeMemory(address, data)
+        return
+    }
+    mmu.writeBus(address, data)
+}
+
+// Returns an 8-bit value at the given address
+// Without watchpoints this is a nil check in front of readBus
+func (mmu *MMU) read8(address uint16) uint8 {
+    if mmu.watchpoints != nil {
+        return mmu.watchedRead8(address)
+    }
+    return mmu.readBus(address)
+}
+
+// readBus - What the CPU sees when it reads the address
+func (mmu *MMU) readBus(address uint16) uint8 {
+    if mmu.dmaBlocksAddress(address) {
+        return 0xFF
+    } else if address < 0xFF00 {
+        return mmu.readMemory(address)
+    } else if address == 0xFF00 { // P1 (joy pad info)
+        return mmu.readP1()
+    } else if address == 0xFF01  { // Serial transfer data
+        return mmu.internalRAM[0xFF01]
+    } else if address == 0xFF02 { // SC control. Transfers are never started, unused bits read as 1
+        return mmu.internalRAM[0xFF02] | 0x7E
+    } else if address >= 0xFF10 && address <= 0xFF3F { // Sound registers and wave RAM
+        return mmu.apu.read(address)
+    } else if address == 0xFF41 { 
+        return mmu.calculateSTAT()
+    }
+
+    return mmu.internalRAM[address]
+}
+
+// Returns a 16-bit value starting from the given address
+// The value returned is formed by: <*address> | <*address+1> << 8
+func (mmu *MMU) read16(address uint16) uint16 {
+    // TODO: Fix this
+    return uint16(mmu.read8(address)) | uint16(mmu.read8(address+1)) << 8
+}
+
+// Writes an 8-bit value to the 16-bit address provided.
+// Without watchpoints this is a nil check in front of writeBus
+func (mmu *MMU) write8(address uint16, data uint8) {
+    if mmu.watchpoints != nil {
+        mmu.watchedWrite8(address, data)
+        return
+    }
+    mmu.writeBus(address, data)
+}
+
+// writeBus - Performs a write by the CPU
+func (mmu *MMU) writeBus(address uint16, data uint8) {
+    if mmu.dmaBlocksAddress(address) {
+        // The memory bus is busy with the DMA transfer
+    } else if address < 0xFF00 {
+        mmu.writeMemory(address, data)
+    } else if address == 0xFF00 {
+        mmu.writeP1(data)
+    } else if address == 0xFF01 { // Writing to the serial port; used by the test ROM to give output
+        if mmu.serialOutput != nil {
+            mmu.serialOutput.Write([]byte{data})
+        }
+        mmu.internalRAM[0xFF01] = data
+    } else if address == 0xFF02 {
+        // SC - no link cable; transfers are never started
+        mmu.internalRAM[0xFF02] = data & 0x81
+    } else if address == 0xFF04 {
+        if mmu.internalRAM[0xFF04] & 0x10 != 0 { // Resetting DIV is a falling edge of bit 4
+            mmu.apu.clockFrameSequencer()
+        }
+        mmu.internalRAM[0xFF04] = 0 // Increment the DIV (divider register) always resets it to 0
+    } else if address >= 0xFF10 && address <= 0xFF3F {
+        mmu.apu.write(address, data)
+    } else if address == 0xFF41 {
+        mmu.internalRAM[0xFF41] = data & 0x78 // Only bits 3-6 are writeable
+        mmu.updateSTATInterrupt()
+    } else if address == 0xFF44 {
+        mmu.internalRAM[0xFF44] = 0 // Incrementing LY (LCDC ycoordinate) always reset it to zero
+        mmu.updateSTATInterrupt()
+    } else if address == 0xFF45 {
+        mmu.internalRAM[0xFF45] = data
+        mmu.updateSTATInterrupt()
+    } else if address == 0xFF46 {
+        mmu.internalRAM[0xFF46] = data
+        mmu.dma.start(uint16(data) << 8)
+    } else {
+        mmu.internalRAM[address] = data
+    }
+}
+
+// Writes a 16-bit value to the 16-bit address provided
+// The low byte of data is stored at (address)
+// The high byte of data is stored at (address+1)
+func (mmu *MMU) write16(address uint16, data uint16) {
+    mmu.write8(address,uint8(data & 0xFF))
+    mmu.write8(address+1,uint8(data >> 8))
+}
+
+// incrementDIV - Increment the divider register
+// This register cannot be written to normally (writing to it resets it)
+// It is only ever incremented by the Timer and only by 1
+// The APU frame sequencer is clocked whenever bit 4 of DIV goes from 1 to 0 (512Hz)
+func (mmu * MMU) incrementDIV() {
+    before := mmu.internalRAM[0xFF04]
+    mmu.internalRAM[0xFF04]++
+    if before & 0x10 != 0 && mmu.internalRAM[0xFF04] & 0x10 == 0 {
+        mmu.apu.clockFrameSequencer()
+    }
+}
+
+// getTIMA - Returns the value of the 8-bit timer register
+func (mmu * MMU) getTIMA() uint8{
+    return mmu.readBus(0xFF05)
+}
+
+func (mmu * MMU) setTIMA(newValue uint8) {
+    mmu.writeBus(0xFF05,newValue)
+}
+
+// getTMA - returns the timer modulator
+// This is the value that TIMA is set to for every overflow
+func (mmu * MMU) getTMA() uint8{
+    return mmu.readBus(0xFF06)
+}
+
+// getTAC() - Returns the value inside the timer control register
+func (mmu * MMU) getTAC() uint8 {
+    return mmu.readBus(0xFF07)
+}
+
+// getIF() - Returns the value of the interrupt flag
+func (mmu * MMU) getIF() uint8{
+    return mmu.readBus(0xFF0F)
+}
+
+// setIF() - Sets the interrupt flag to new values
+// This may trigger an interrupt routine during the next instruction
+func (mmu * MMU) setIF(newValue uint8){
+    mmu.writeBus(0xFF0F,newValue)
+}
+
+// getIE() - Returns the value of the interrupt enabled register
+func (mmu * MMU) getIE() uint8 {
+    return mmu.readBus(0xFFFF)
+}
+
+// showDisplay - Returns true if the display should be shown
+func (mmu * MMU) showDisplay() bool {
+    return (mmu.readBus(0xFF40) >> 7) == 0x1
+}
+
+// backgroundEnabled - LCDC bit 0. On the DMG a cleared bit blanks the background to color 0
+func (mmu * MMU) backgroundEnabled() bool {
+    return mmu.readBus(0xFF40) & 0x1 == 0x1
+}
+
+// spritesEnabled - LCDC bit 1
+func (mmu * MMU) spritesEnabled() bool {
+    return (mmu.readBus(0xFF40) >> 1) & 0x1 == 0x1
+}
+
+// spriteHeight - LCDC bit 2 selects between 8x8 and 8x16 sprites
+func (mmu * MMU) spriteHeight() uint8 {
+    if (mmu.readBus(0xFF40) >> 2) & 0x1 == 0x1 {
+        return 16
+    }
+    return 8
+}
+
+// backgroundPalette - Returns BGP (0xFF47), which is shared by the background and the window
+func (mmu * MMU) backgroundPalette() uint8 {
+    return mmu.readBus(0xFF47)
+}
+
+// objectPalette - Returns OBP0 (0xFF48) or OBP1 (0xFF49)
+func (mmu * MMU) objectPalette(palette uint8) uint8 {
+    return mmu.readBus(0xFF48 + uint16(palette))
+}
+
+// bgTileDataAddress - Returns the address of the given tileNumber
+// based on which tileData region is selected in LCDC. Used by both the background and the window
+//
+//   LCDC bit 4 = 1: tiles 0 to 255 are at 0x8000-0x8FFF
+//   LCDC bit 4 = 0: tiles -128 to 127 (tileNumber is signed) are at 0x8800-0x97FF,
+//                   so tile 0 is at 0x9000 and tile 0xFF (-1) at 0x8FF0
+func (mmu * MMU) bgTileDataAddress(tileNumber uint8) uint16 {
+    if ((mmu.readBus(0xFF40) >> 4) & 0x1) == 0x1 {
+        return 0x8000 + uint16(tileNumber)*16
+    }
+    return uint16(int(0x9000) + int(int8(tileNumber))*16)
+}
+
+// bgTileMapStartAddress - Returns the start of 1024-byte area which
+// contains 32x32 tilemap to use
+func (mmu *MMU) bgTileMapStartAddress() uint16 {
+    if ((mmu.readBus(0xFF40) >> 3) & 0x1) == 0x1 {
+        return 0x9C00
+    }
+    return 0x9800
+}
+
+// windowEnabled - LCDC bit 5
+func (mmu * MMU) windowEnabled() bool {
+    return (mmu.readBus(0xFF40) >> 5) & 0x1 == 0x1
+}
+
+// windowTileMapStartAddress - Same as bgTileMapStartAddress, but selected by LCDC bit 6
+func (mmu *MMU) windowTileMapStartAddress() uint16 {
+    if ((mmu.readBus(0xFF40) >> 6) & 0x1) == 0x1 {
+        return 0x9C00
+    }
+    return 0x9800
+}
+
+
+func (mmu *MMU) setSTATMode(mode uint8){
+    mmu.statMode = mode
+    mmu.updateSTATInterrupt()
+}
+
+// updateSTATInterrupt - All of the enabled STAT sources are OR'd together into a single line
+// and the LCD STAT interrupt (IF bit 1) is only requested when that line goes from low to high.
+// While one source keeps the line high, other sources can not cause another interrupt
+// ("STAT blocking"); for example an LY=LYC match during H-Blank with both enabled
+//
+//   STAT bit 6  LY=LYC
+//   STAT bit 5  Mode 2 (OAM search)
+//   STAT bit 4  Mode 1 (V-Blank)
+//   STAT bit 3  Mode 0 (H-Blank)
+func (mmu * MMU) updateSTATInterrupt() {
+    stat := mmu.internalRAM[0xFF41]
+    line := false
+    if mmu.showDisplay() {
+        line = (stat & 0x40 != 0 && mmu.getLY() == mmu.getLYC()) ||
+            (stat & 0x20 != 0 && mmu.statMode == 0x2) ||
+            (stat & 0x10 != 0 && mmu.statMode == 0x1) ||
+            (stat & 0x08 != 0 && mmu.statMode == 0x0)
+    }
+
+    if line && !mmu.statLine {
+        mmu.setIF(mmu.getIF() | 0x2)
+    }
+    mmu.statLine = line
+}
+
+// Combines the R/W flags from the internalRAM and also the special statMode
+// flags set by the display.update function
+func (mmu * MMU) calculateSTAT() uint8 {
+    stat := mmu.internalRAM[0xFF41] | mmu.statMode
+    if mmu.getLY() == mmu.getLYC(){
+        stat = stat | 0x4 // Set bit 2 (coincident flag)
+    }
+    return stat
+}
+
+func (mmu * MMU) scrollY() uint8 {
+    return mmu.readBus(0xFF42)
+}
+
+func (mmu * MMU) scrollX() uint8 {
+    return mmu.readBus(0xFF43)
+}
+
+func (mmu * MMU) windowY() uint8 {
+    return mmu.readBus(0xFF4A)
+}
+
+func (mmu *MMU) windowX() uint8 {
+    return mmu.readBus(0xFF4B)
+}
+
+// getLY - Returns the value of the LY register (LCD Y; aka current scanline)
+func (mmu * MMU) getLY() uint8 {
+    return mmu.readBus(0xFF44)
+}
+
+// getLYC - Returns the value of the LYC (line y compare) register. Setting
+// this value allows for an interrupt whenever LY=LYC
+func (mmu * MMU) getLYC() uint8 {
+    return mmu.readBus(0xFF45)
+}
+
+
+// incrementLY - Handles incrementing the LCD Y-Register & setting interrupts
+func (mmu * MMU) incrementLY() {
+    currentScanline := mmu.readBus(0xFF44)
+    currentScanline++
+    if currentScanline == 144 {
+        mmu.setIF(mmu.getIF() | 0x1) // Trigger vblank!
+        mmu.internalRAM[0xFF44] = currentScanline
+    } else if currentScanline > 153 { // Max number of scanlines have been reached
+        mmu.internalRAM[0xFF44] = 0
+    } else {
+        mmu.internalRAM[0xFF44] = currentScanline
+    }
+    mmu.updateSTATInterrupt()
+}
+
+// backgroundColorNumberAt(x,y)
+// x,y are coordinates in the BG tile space. To read the interleaved pixel color, do the following:
+//  1) Calculate which tile the pixel is in. The tilespace is 32x32 8px tiles in size
+//  2) Calculate the address where the tile starts in memory
+//  3) Calculate the exact byte which contains the pixel data
+//  4) Calculate which bit in the two bytes contain the pixel data
+//       
+//       tileAddress [0L][1L][2L][3L][4L][5L][6L][7L] // Two bytes contain the
+//           +1      [0H][1H][2H][3H][4H][5H][6H][7H] // color data for 8 pixels
+//          ...
+//          +14      [         last two bytes       ]
+//          +15      [        last eight pixels     ]
+// Returns the 2-bit color number; the palette is applied by the display
+func (mmu * MMU) backgroundColorNumberAt(x uint8, y uint8) uint8 {
+    return mmu.tileMapColorNumberAt(mmu.bgTileMapStartAddress(), x, y)
+}
+
+// windowColorNumberAt - Same as backgroundColorNumberAt, but x,y are in window space
+func (mmu * MMU) windowColorNumberAt(x uint8, y uint8) uint8 {
+    return mmu.tileMapColorNumberAt(mmu.windowTileMapStartAddress(), x, y)
+}
+
+// tileMapColorNumberAt - Looks up the pixel x,y of the 32x32 tile map starting at tileMapStart
+func (mmu * MMU) tileMapColorNumberAt(tileMapStart uint16, x uint8, y uint8) uint8 {
+    // 32 tiles per row. y>>3 (same as y/8) gets the row. x>>3 (x/8) gets the columns
+    tileMapOffset := (uint16(x)>>3) + (uint16(y)>>3)*32
+    tileSelectionAddress := tileMapStart + uint16(tileMapOffset)
+    tileNumber := mmu.readMemory(tileSelectionAddress) // Which one of 256 tiles are to be shown
+    tileDataAddress := mmu.bgTileDataAddress(tileNumber) // Where the 16-bytes of the tile begin
+
+    tileYOffset := (y & 0x7)*2 // Each row in the tile takes 2 bytes
+    return mmu.tileColorNumber(tileDataAddress + uint16(tileYOffset), x & 0x7)
+}
+
+// tileColorNumber - Returns the 2-bit color number of column x (0-7) of the tile row
+// which starts at rowAddress
+func (mmu * MMU) tileColorNumber(rowAddress uint16, x uint8) uint8 {
+    pixLow := (mmu.vram[rowAddress-0x8000] >> (7-x)) & 0x1
+    pixHigh := (mmu.vram[rowAddress-0x8000+1] >> (7-x)) & 0x1
+    return (pixHigh << 1) | pixLow
+}
+
+func createMMU() *MMU {
+    mmu := new(MMU)
+    mmu.internalRAM = make([]uint8, 65536) // Pre-allocate all that beautiful unused memory
+    mmu.internalRAM[0xFF00] = 0x30 // Neither row of buttons selected
+    mmu.apu = newAPU()
+    mmu.internalRAM[0xFF47] = 0xFC // BGP as it is left behind by the boot ROM
+    return mmu
+}
@@ -0,0 +1,100 @@
+package gmb
+
+import "testing"
+
+func TestMemoryMap(t *testing.T) {
+    cpu := testCPU()
+    mmu := cpu.mmu
+
+    mmu.write8(0xC123, 0x11)
+    if mmu.read8(0xE123) != 0x11 {
+        t.Errorf("Echo RAM does not mirror WRAM")
+    }
+    mmu.write8(0xFDFF, 0x22)
+    if mmu.read8(0xDDFF) != 0x22 {
+        t.Errorf("Writes to echo RAM should end up in WRAM")
+    }
+
+    mmu.write8(0x8010, 0x33)
+    mmu.write8(0xFE9F, 0x44)
+    mmu.write8(0xFF90, 0x55)
+    if mmu.vram[0x10] != 0x33 || mmu.oam[0x9F] != 0x44 || mmu.read8(0xFF90) != 0x55 {
+        t.Errorf("VRAM, OAM or HRAM writes went to the wrong place")
+    }
+    if mmu.read8(0xA000) != 0xFF {
+        t.Errorf("A ROM without RAM should read open bus at 0xA000")
+    }
+
+    mmu.write8(0xFEA0, 0x66)
+    if mmu.read8(0xFEA0) != 0x00 {
+        t.Errorf("The unusable region should read 0x00 while the LCD is off")
+    }
+    mmu.write8(0xFF40, 0x80)
+    mmu.setSTATMode(0x2)
+    if mmu.read8(0xFEFF) != 0xFF {
+        t.Errorf("The unusable region should read 0xFF during OAM search")
+    }
+}
+
+func TestOAMDMA(t *testing.T) {
+    cpu := testCPU()
+    mmu := cpu.mmu
+
+    for i := uint16(0); i < DMALENGTH; i++ {
+        mmu.write8(0xC000+i, uint8(i)+1)
+    }
+    mmu.write8(0xFF80, 0x77)
+    mmu.write8(0xFF46, 0xC0)
+    if mmu.read8(0xFF46) != 0xC0 {
+        t.Errorf("0xFF46 should read back the last source page")
+    }
+
+    mmu.updateDMA(4) // The instruction which wrote 0xFF46
+    mmu.updateDMA(4 * 10)
+    if mmu.oam[9] != 10 || mmu.oam[10] != 0 {
+        t.Errorf("DMA should copy one byte per M-cycle, got oam[9]=%d oam[10]=%d", mmu.oam[9], mmu.oam[10])
+    }
+    if mmu.read8(0xC000) != 0xFF {
+        t.Errorf("WRAM should be unreachable during DMA")
+    }
+    mmu.write8(0xC000, 0x99)
+    if mmu.read8(0xFF80) != 0x77 {
+        t.Errorf("HRAM should stay reachable during DMA")
+    }
+
+    mmu.updateDMA(4 * (DMALENGTH - 10))
+    if mmu.dma.active {
+        t.Errorf("DMA should be finished after 160 M-cycles")
+    }
+    if mmu.read8(0xC000) != 0x01 {
+        t.Errorf("Writes during DMA should be dropped")
+    }
+    for i := 0; i < DMALENGTH; i++ {
+        if mmu.oam[i] != uint8(i)+1 {
+            t.Fatalf("oam[%d] = %d, expected %d", i, mmu.oam[i], i+1)
+        }
+    }
+}
+
+func TestOAMDMAStartsAfterTheWritingInstruction(t *testing.T) {
+    cpu := testCPU()
+    mmu := cpu.mmu
+    for i := uint16(0); i < DMALENGTH; i++ {
+        mmu.write8(0xC000+i, uint8(i)+1)
+    }
+    mmu.write8(0xC100, 0xE0) // LDH ($46),A
+    mmu.write8(0xC101, 0x46)
+    cpu.ra = 0xC0
+    cpu.programCounter = 0xC100
+
+    if _, err := cpu.step(); err != nil {
+        t.Fatal(err)
+    }
+    if mmu.oam[0] != 0 || mmu.dma.bytesCopied != 0 {
+        t.Errorf("Nothing should be copied by the instruction which started the DMA")
+    }
+    mmu.updateDMA(4)
+    if mmu.oam[0] != 1 || mmu.oam[1] != 0 || mmu.dma.bytesCopied != 1 {
+        t.Errorf("One byte should be copied in the next M-cycle, copied %d", mmu.dma.bytesCopied)
+    }
+}
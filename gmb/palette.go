@@ -0,0 +1,61 @@
+package gmb
+
+import (
+    "fmt"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// Palette - The RGBA colors that the four DMG shades (0=lightest, 3=darkest) are shown as.
+// The palette registers (BGP, OBP0, OBP1) pick a shade for every 2-bit color number;
+// the Palette only decides what those shades look like on the screen
+type Palette [4]int
+
+// Palettes - The built in output palettes, selectable by name
+// Lots of alternate palettes available here: https://lospec.com/palette-list/tag/gameboy
+var Palettes = map[string]Palette{
+    "gray":   GameBoyColorMap,
+    "dmg":    { 0x9BBC0FFF, 0x8BAC0FFF, 0x306230FF, 0x0F380FFF }, // DMG-like green
+    "pocket": { 0xC4CFA1FF, 0x8B956DFF, 0x4D533CFF, 0x1F1F1FFF },
+}
+
+// PaletteNames - Returns the names of the built in palettes in alphabetical order
+func PaletteNames() []string {
+    names := make([]string, 0, len(Palettes))
+    for name := range Palettes {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// ParsePalette - Returns the built in palette with the given name, or parses a custom one
+// written as four comma separated RRGGBB hex colors from lightest to darkest
+// (for example "E0F8D0,88C070,346856,081820")
+func ParsePalette(description string) (Palette, error) {
+    if palette, ok := Palettes[description]; ok {
+        return palette, nil
+    }
+
+    colors := strings.Split(description, ",")
+    if len(colors) != 4 {
+        return Palette{}, fmt.Errorf("unknown palette %q: expected one of %s or four RRGGBB colors",
+            description, strings.Join(PaletteNames(), ", "))
+    }
+    var palette Palette
+    for i, color := range colors {
+        color = strings.TrimPrefix(strings.TrimSpace(color), "#")
+        value, err := strconv.ParseUint(color, 16, 32)
+        if err != nil || len(color) != 6 {
+            return Palette{}, fmt.Errorf("invalid palette color %q: expected RRGGBB", colors[i])
+        }
+        palette[i] = int(value)<<8 | 0xFF
+    }
+    return palette, nil
+}
+
+// shade - Applies a palette register to a 2-bit color number. Returns the shade (0-3)
+func shade(paletteRegister uint8, colorNumber uint8) uint8 {
+    return (paletteRegister >> (colorNumber*2)) & 0x3
+}
@@ -0,0 +1,174 @@
+package gmb
+
+import (
+    "bytes"
+    "compress/flate"
+    "encoding/binary"
+    "errors"
+    "io/ioutil"
+)
+
+// REWINDKEYFRAMEINTERVAL - Every this many snapshots a full keyframe is stored; the ones
+// in between only store what changed since the keyframe
+const REWINDKEYFRAMEINTERVAL = 30
+
+// ErrNothingToRewind - Returned by StepBack when the rewind buffer is empty or disabled
+var ErrNothingToRewind = errors.New("nothing left to rewind")
+
+// rewindGroup - A keyframe and the snapshots which were delta-encoded against it.
+// Everything is stored compressed
+type rewindGroup struct {
+    keyframe []byte
+    deltas [][]byte
+}
+
+// RewindBuffer - A ring buffer of save states. The states are XOR'd against the keyframe
+// of their group section by section (see xorSections), which leaves mostly zeros that compress very well. When the buffer is
+// full the oldest group is dropped as a whole, since its deltas can not be decoded without it
+type RewindBuffer struct {
+    groups []*rewindGroup // Oldest first
+    count int // Snapshots in all groups
+    capacity int
+    newestKeyframe []byte // Uncompressed keyframe of the last group, used to encode new deltas
+}
+
+// newRewindBuffer - Keeps at least capacity snapshots
+func newRewindBuffer(capacity int) *RewindBuffer {
+    if capacity < 1 {
+        capacity = 1
+    }
+    return &RewindBuffer{capacity: capacity}
+}
+
+// push - Adds the newest snapshot
+func (rewind *RewindBuffer) push(state []byte) {
+    last := rewind.newestGroup()
+    if last == nil || len(last.deltas)+1 >= REWINDKEYFRAMEINTERVAL {
+        rewind.groups = append(rewind.groups, &rewindGroup{keyframe: compress(state)})
+        rewind.newestKeyframe = state
+    } else {
+        last.deltas = append(last.deltas, compress(xorSections(state, rewind.newestKeyframe)))
+    }
+    rewind.count++
+
+    for len(rewind.groups) > 1 && rewind.count-rewind.groupSize(rewind.groups[0]) >= rewind.capacity {
+        rewind.count -= rewind.groupSize(rewind.groups[0])
+        rewind.groups = rewind.groups[1:]
+    }
+}
+
+// pop - Removes the newest snapshot and returns it. Returns nil when the buffer is empty
+func (rewind *RewindBuffer) pop() []byte {
+    last := rewind.newestGroup()
+    if last == nil {
+        return nil
+    }
+    rewind.count--
+
+    if len(last.deltas) > 0 {
+        delta := decompress(last.deltas[len(last.deltas)-1])
+        last.deltas = last.deltas[:len(last.deltas)-1]
+        return xorSections(delta, rewind.newestKeyframe)
+    }
+
+    state := rewind.newestKeyframe
+    rewind.groups = rewind.groups[:len(rewind.groups)-1]
+    rewind.newestKeyframe = nil
+    if previous := rewind.newestGroup(); previous != nil {
+        rewind.newestKeyframe = decompress(previous.keyframe)
+    }
+    return state
+}
+
+// len - The number of snapshots in the buffer
+func (rewind *RewindBuffer) len() int {
+    return rewind.count
+}
+
+func (rewind *RewindBuffer) newestGroup() *rewindGroup {
+    if len(rewind.groups) == 0 {
+        return nil
+    }
+    return rewind.groups[len(rewind.groups)-1]
+}
+
+func (rewind *RewindBuffer) groupSize(group *rewindGroup) int {
+    return 1 + len(group.deltas)
+}
+
+// xorSections - XORs the payload of every section of a save state with the payload of the
+// same section of key; the magic, version and section headers are copied as they are.
+// Gob encodes small or zero fields with fewer bytes, so a section can change its length
+// from one frame to the next. Aligning every section on its own keeps that from shifting
+// memory in the sections after it (the memory itself comes first in each section).
+// Applying it twice gives back the original
+func xorSections(state []byte, key []byte) []byte {
+    result := make([]byte, 0, len(state))
+    statePrefix, stateChunks := splitSections(state)
+    _, keyChunks := splitSections(key)
+    result = append(result, statePrefix...)
+    for i, chunk := range stateChunks {
+        if len(chunk) < 8 {
+            result = append(result, chunk...)
+            continue
+        }
+        var keyPayload []byte
+        if i < len(keyChunks) && len(keyChunks[i]) >= 8 {
+            keyPayload = keyChunks[i][8:]
+        }
+        result = append(result, chunk[:8]...)
+        result = append(result, xorBytes(chunk[8:], keyPayload)...)
+    }
+    return result
+}
+
+// splitSections - Splits a save state into the magic and version, and the sections with their
+// 8-byte headers. A section which claims to be longer than the data gets what is left,
+// and a trailing piece shorter than a header is returned as it is
+func splitSections(state []byte) ([]byte, [][]byte) {
+    if len(state) < 6 {
+        return state, nil
+    }
+    var chunks [][]byte
+    for data := state[6:]; len(data) > 0; {
+        if len(data) < 8 { // Not a whole header
+            chunks = append(chunks, data)
+            break
+        }
+        length := int(binary.LittleEndian.Uint32(data[4:]))
+        if length > len(data)-8 {
+            length = len(data) - 8
+        }
+        chunks = append(chunks, data[:8+length])
+        data = data[8+length:]
+    }
+    return state[:6], chunks
+}
+
+// xorBytes - Returns data XOR'd with key. The result has the length of data; bytes past
+// the end of key are copied as they are. Applying it twice gives back the original
+func xorBytes(data []byte, key []byte) []byte {
+    result := make([]byte, len(data))
+    for i := range data {
+        if i < len(key) {
+            result[i] = data[i] ^ key[i]
+        } else {
+            result[i] = data[i]
+        }
+    }
+    return result
+}
+
+func compress(data []byte) []byte {
+    var buffer bytes.Buffer
+    writer, _ := flate.NewWriter(&buffer, flate.BestSpeed) // Only fails for invalid levels
+    writer.Write(data)
+    writer.Close()
+    return buffer.Bytes()
+}
+
+// decompress - The data was compressed by this process, so it can not be corrupt
+func decompress(data []byte) []byte {
+    result, _ := ioutil.ReadAll(flate.NewReader(bytes.NewReader(data)))
+    return result
+}
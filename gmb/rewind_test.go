@@ -0,0 +1,98 @@
+package gmb
+
+import (
+    "bytes"
+    "fmt"
+    "math/rand"
+    "testing"
+)
+
+func TestRewindBuffer(t *testing.T) {
+    rewind := newRewindBuffer(50)
+    snapshot := func(i int) []byte {
+        state := bytes.Repeat([]byte{0xAB}, 1000)
+        copy(state[100:], fmt.Sprintf("snapshot %d", i))
+        return state[:1000-i%3] // Sizes do not have to match the keyframe
+    }
+    for i := 0; i < 100; i++ {
+        rewind.push(snapshot(i))
+    }
+    if rewind.len() < 50 || rewind.len() >= 50+REWINDKEYFRAMEINTERVAL {
+        t.Errorf("Buffer holds %d snapshots, expected 50 to %d", rewind.len(), 50+REWINDKEYFRAMEINTERVAL)
+    }
+
+    kept := rewind.len()
+    for i := 99; i > 99-kept; i-- {
+        if !bytes.Equal(rewind.pop(), snapshot(i)) {
+            t.Fatalf("Snapshot %d did not decode to what was pushed", i)
+        }
+    }
+    if rewind.pop() != nil || rewind.len() != 0 {
+        t.Errorf("The buffer should be empty")
+    }
+}
+
+func TestStepBack(t *testing.T) {
+    gb, err := New(validROM(0x00, 2, 0x00), WithRewind(1, 1))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if gb.StepBack() != ErrNothingToRewind {
+        t.Errorf("Stepping back without snapshots should fail")
+    }
+
+    var executed []uint64
+    var pixels []uint8
+    for i := 0; i < 5; i++ {
+        gb.Framebuffer().Pix[0] = uint8(i) // The LCD is off, so mark the frames by hand
+        gb.RunFrame()
+        executed = append(executed, gb.cpu.instructionsExecuted)
+        pixels = append(pixels, uint8(i))
+    }
+    for i := 3; i >= 0; i-- {
+        if err := gb.StepBack(); err != nil {
+            t.Fatal(err)
+        }
+        if gb.cpu.instructionsExecuted != executed[i] {
+            t.Errorf("Expected to be back at frame %d", i+1)
+        }
+        if gb.Framebuffer().Pix[0] != pixels[i] {
+            t.Errorf("The picture of frame %d should be restored", i+1)
+        }
+    }
+    if gb.StepBack() != ErrNothingToRewind {
+        t.Errorf("The first frame should be the oldest snapshot")
+    }
+}
+
+// The deltas must stay small when the CPU section changes size between frames: gob leaves out
+// zero fields and writes integers with a variable length
+func TestRewindDeltaSize(t *testing.T) {
+    gb, err := New(validROM(0x00, 2, 0x00))
+    if err != nil {
+        t.Fatal(err)
+    }
+    random := rand.New(rand.NewSource(1))
+    random.Read(gb.cpu.mmu.wram[:]) // Memory which does not compress by itself
+    random.Read(gb.cpu.mmu.vram[:])
+    rewind := newRewindBuffer(10)
+    snapshot := func() {
+        var state bytes.Buffer
+        gb.SaveState(&state)
+        rewind.push(state.Bytes())
+    }
+    snapshot()
+    gb.cpu.ra, gb.cpu.rb, gb.cpu.rc = 0x90, 0x01, 0xFF
+    gb.cpu.stackPointer, gb.cpu.carry = 0xFFF0, true
+    gb.cpu.instructionsExecuted = 1 << 40
+    gb.display.scanlineCounter = 300
+    snapshot()
+
+    group := rewind.groups[0]
+    if len(group.keyframe) < 0x4000 {
+        t.Fatalf("The keyframe should not compress well, got %d bytes", len(group.keyframe))
+    }
+    if len(group.deltas[0]) > 1024 { // About 270 bytes, mostly flate overhead; misaligned it was 18KB
+        t.Errorf("A delta of a few registers took %d bytes", len(group.deltas[0]))
+    }
+}
@@ -0,0 +1,121 @@
+package gmb
+
+import ( 
+    //"fmt"
+    "bytes"
+    "image/png"
+    "os"
+    "testing"
+    "time"
+    "io/ioutil"
+    "strings"
+)
+
+func checkOutput(t * testing.T, romName string, output string){
+    if !strings.Contains(output,"Passed") {
+        t.Errorf("ROM %s did not get a passing result", romName)
+    }
+}
+
+func runROM(t *testing.T, directory string, romName string){
+    fullROMName := directory + "/" + romName
+
+    // The test ROMs print their results to the serial port
+    var buf bytes.Buffer
+    gb, err := Load(fullROMName, WithDisplay(false), WithSerialOutput(&buf), WithSaveFile(""))
+    if err != nil {
+        t.Errorf("ROM %s could not be loaded: %s", romName, err)
+        return
+    }
+
+    // Run until the ROM reports a result or the time is up
+    timeout := time.After(1500 * time.Millisecond)
+    for {
+        select {
+        case <-timeout:
+            checkOutput(t,romName, buf.String())
+            return
+        default:
+        }
+
+        if err := gb.RunFrame(); err != nil {
+            t.Errorf("ROM %s stopped: %s", romName, err)
+            return
+        }
+        if strings.Contains(buf.String(), "Passed") || strings.Contains(buf.String(), "Failed") {
+            checkOutput(t,romName, buf.String())
+            return
+        }
+    }
+}
+
+// TestROMs - Automatically runs the test ROMs and turns them into test case results
+func TestROMs(t *testing.T) {
+    romDirectory := "../gb-test-roms/cpu_instrs/individual"
+    files, err := ioutil.ReadDir(romDirectory)
+    if err != nil {
+        t.Errorf("Could not open directory %s",romDirectory)
+    }
+    for _, file := range files {
+        runROM(t, romDirectory, file.Name())
+    }
+}
+
+// shadeOf - Turns a gray RGBA value into a 0 (white) - 3 (black) shade so that screenshots
+// can be compared regardless of the output palette
+func shadeOf(gray uint8, palette Palette) int {
+    best := 0
+    for i, color := range palette {
+        diff := int(gray) - (color >> 24) & 0xFF
+        bestDiff := int(gray) - (palette[best] >> 24) & 0xFF
+        if diff*diff < bestDiff*bestDiff {
+            best = i
+        }
+    }
+    return best
+}
+
+// TestDMGAcid2 - Renders dmg-acid2 and compares the frame against the reference screenshot
+func TestDMGAcid2(t *testing.T) {
+    romName := "../dmg-acid2/dmg-acid2.gb"
+    referenceName := "../dmg-acid2/img/reference-dmg.png"
+    if _, err := os.Stat(romName); err != nil {
+        t.Skipf("%s is not available", romName)
+    }
+
+    file, err := os.Open(referenceName)
+    if err != nil {
+        t.Fatalf("Could not open %s: %s", referenceName, err)
+    }
+    defer file.Close()
+    reference, err := png.Decode(file)
+    if err != nil {
+        t.Fatalf("Could not decode %s: %s", referenceName, err)
+    }
+
+    gb, err := Load(romName, WithSaveFile(""))
+    if err != nil {
+        t.Fatalf("ROM %s could not be loaded: %s", romName, err)
+    }
+    for frame := 0; frame < 10; frame++ {
+        if err := gb.RunFrame(); err != nil {
+            t.Fatalf("ROM %s stopped: %s", romName, err)
+        }
+    }
+
+    referencePalette := Palette{ 0xFFFFFFFF, 0xAAAAAAFF, 0x555555FF, 0x000000FF }
+    screen := gb.Framebuffer()
+    mismatches := 0
+    for y := 0; y < int(LCDHEIGHT); y++ {
+        for x := 0; x < int(LCDWIDTH); x++ {
+            r, _, _, _ := reference.At(x, y).RGBA()
+            got := screen.RGBAAt(x, y)
+            if shadeOf(uint8(r>>8), referencePalette) != shadeOf(got.R, GameBoyColorMap) {
+                mismatches++
+            }
+        }
+    }
+    if mismatches > 0 {
+        t.Errorf("%d pixels differ from the dmg-acid2 reference image", mismatches)
+    }
+}
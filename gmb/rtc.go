@@ -0,0 +1,171 @@
+package gmb
+
+import (
+    "encoding/binary"
+    "time"
+)
+
+// ClockSource - Returns the current wall clock time. The real time clock asks it for the time
+// instead of calling time.Now directly so that tests can drive the clock themselves
+type ClockSource func() time.Time
+
+// RealTimeClock - The MBC3 real time clock
+//
+//   0x08  RTC S   Seconds   0-59
+//   0x09  RTC M   Minutes   0-59
+//   0x0A  RTC H   Hours     0-23
+//   0x0B  RTC DL  Lower 8 bits of the day counter
+//   0x0C  RTC DH  Bit 0: Upper bit of the day counter
+//                 Bit 6: Halt (0 = active, 1 = stop timer)
+//                 Bit 7: Day counter carry bit (1 = counter overflowed)
+//
+// The game never reads the live registers. Writing 0x00 then 0x01 to 0x6000-0x7FFF
+// copies (latches) the live registers and every read returns the latched copy
+type RealTimeClock struct {
+    seconds uint8
+    minutes uint8
+    hours uint8
+    days uint16 // 9-bit day counter
+    halted bool
+    dayCarry bool
+
+    latched [5]uint8 // Latched copy of registers 0x08-0x0C
+    latchPrimed bool // Set when 0x00 was the last value written to the latch register
+
+    lastUpdate time.Time // Wall clock time that the live registers correspond to
+    clock ClockSource
+}
+
+// update - Advances the live registers by the number of whole seconds which have
+// passed since the last update. Nothing happens while the clock is halted
+func (rtc *RealTimeClock) update() {
+    now := rtc.clock()
+    if rtc.halted {
+        rtc.lastUpdate = now
+        return
+    }
+
+    elapsed := int64(now.Sub(rtc.lastUpdate) / time.Second)
+    if elapsed <= 0 {
+        return
+    }
+    rtc.lastUpdate = rtc.lastUpdate.Add(time.Duration(elapsed) * time.Second)
+    rtc.advance(elapsed)
+}
+
+// advance - Adds the number of seconds to the live registers, carrying into the next
+// register on overflow. The day counter sets the carry bit when it passes 511
+func (rtc *RealTimeClock) advance(seconds int64) {
+    total := int64(rtc.seconds) + seconds
+    rtc.seconds = uint8(total % 60)
+    total = int64(rtc.minutes) + total/60
+    rtc.minutes = uint8(total % 60)
+    total = int64(rtc.hours) + total/60
+    rtc.hours = uint8(total % 24)
+    days := int64(rtc.days) + total/24
+    if days > 0x1FF {
+        rtc.dayCarry = true // Stays set until the game clears it
+        days %= 0x200
+    }
+    rtc.days = uint16(days)
+}
+
+// dayHigh - Returns the value of the DH register
+func (rtc *RealTimeClock) dayHigh() uint8 {
+    dh := uint8(rtc.days>>8) & 0x1
+    if rtc.halted {
+        dh |= 0x40
+    }
+    if rtc.dayCarry {
+        dh |= 0x80
+    }
+    return dh
+}
+
+// latch - Copies the live registers into the latched registers
+func (rtc *RealTimeClock) latch() {
+    rtc.update()
+    rtc.latched = [5]uint8{rtc.seconds, rtc.minutes, rtc.hours, uint8(rtc.days & 0xFF), rtc.dayHigh()}
+}
+
+// writeLatch - Handles writes to 0x6000-0x7FFF. The registers are latched on a 0x00 -> 0x01 sequence
+func (rtc *RealTimeClock) writeLatch(data uint8) {
+    if rtc.latchPrimed && data == 0x01 {
+        rtc.latch()
+    }
+    rtc.latchPrimed = data == 0x00
+}
+
+// read - Returns the latched value of the register (0x08-0x0C)
+func (rtc *RealTimeClock) read(register uint8) uint8 {
+    return rtc.latched[register-0x08]
+}
+
+// write - Sets the live value of the register (0x08-0x0C)
+func (rtc *RealTimeClock) write(register uint8, data uint8) {
+    rtc.update()
+    switch register {
+    case 0x08:
+        rtc.seconds = data & 0x3F
+        rtc.lastUpdate = rtc.clock() // Writing the seconds resets the sub-second counter
+    case 0x09:
+        rtc.minutes = data & 0x3F
+    case 0x0A:
+        rtc.hours = data & 0x1F
+    case 0x0B:
+        rtc.days = (rtc.days & 0x100) | uint16(data)
+    case 0x0C:
+        rtc.days = (rtc.days & 0xFF) | (uint16(data&0x1) << 8)
+        rtc.dayCarry = (data & 0x80) != 0
+        rtc.halted = (data & 0x40) != 0
+    }
+}
+
+// footer - Serializes the clock in the 48-byte format which is appended to .sav files by most emulators
+//   0-19   Live S, M, H, DL, DH registers; one little endian uint32 each
+//   20-39  Latched S, M, H, DL, DH registers
+//   40-47  Unix timestamp which the live registers correspond to (little endian uint64)
+func (rtc *RealTimeClock) footer() []uint8 {
+    rtc.update()
+    data := make([]uint8, 48)
+    live := [5]uint8{rtc.seconds, rtc.minutes, rtc.hours, uint8(rtc.days & 0xFF), rtc.dayHigh()}
+    for i := 0; i < 5; i++ {
+        binary.LittleEndian.PutUint32(data[i*4:], uint32(live[i]))
+        binary.LittleEndian.PutUint32(data[20+i*4:], uint32(rtc.latched[i]))
+    }
+    binary.LittleEndian.PutUint64(data[40:], uint64(rtc.lastUpdate.Unix()))
+    return data
+}
+
+// loadFooter - Restores the clock from a .sav footer. Also accepts the older 44-byte
+// variant which stores a 32-bit timestamp. The time that passed while the emulator
+// was not running is added the next time the registers are accessed
+func (rtc *RealTimeClock) loadFooter(data []uint8) {
+    var timestamp int64
+    if len(data) >= 48 {
+        timestamp = int64(binary.LittleEndian.Uint64(data[40:]))
+    } else if len(data) >= 44 {
+        timestamp = int64(binary.LittleEndian.Uint32(data[40:]))
+    } else {
+        return
+    }
+
+    for i := 0; i < 5; i++ {
+        rtc.latched[i] = uint8(binary.LittleEndian.Uint32(data[20+i*4:]))
+    }
+    rtc.seconds = uint8(binary.LittleEndian.Uint32(data[0:])) & 0x3F
+    rtc.minutes = uint8(binary.LittleEndian.Uint32(data[4:])) & 0x3F
+    rtc.hours = uint8(binary.LittleEndian.Uint32(data[8:])) & 0x1F
+    dh := uint8(binary.LittleEndian.Uint32(data[16:]))
+    rtc.days = uint16(uint8(binary.LittleEndian.Uint32(data[12:]))) | (uint16(dh&0x1) << 8)
+    rtc.halted = (dh & 0x40) != 0
+    rtc.dayCarry = (dh & 0x80) != 0
+    rtc.lastUpdate = time.Unix(timestamp, 0)
+}
+
+func newRealTimeClock(clock ClockSource) *RealTimeClock {
+    rtc := new(RealTimeClock)
+    rtc.clock = clock
+    rtc.lastUpdate = clock()
+    return rtc
+}
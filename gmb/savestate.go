@@ -0,0 +1,307 @@
+package gmb
+
+import (
+    "bytes"
+    "encoding/binary"
+    "encoding/gob"
+    "fmt"
+    "io"
+    "io/ioutil"
+)
+
+// Save state format
+//
+//   "GMBS"             Magic
+//   uint16             Format version (STATEVERSION)
+//   Chunks until the end of the stream, each:
+//     [4]byte          Section ID ("INFO", "CPU ", "MMU ", "TIMR", "DISP", "CART", "APU ")
+//     uint32           Length of the payload
+//     []byte           Payload: a gob encoded section struct
+//
+// All integers are little endian. Gob matches struct fields by name, so fields can be
+// added to a section without breaking old states (missing fields are left at zero) and
+// readers skip the sections they do not know about. STATEVERSION only needs to be bumped
+// when the meaning of an existing field changes
+
+// STATEVERSION - The version written by SaveState. LoadState reads this version and older
+const STATEVERSION = 1
+
+var stateMagic = []byte("GMBS")
+
+// infoState - Identifies the ROM that the state belongs to
+type infoState struct {
+    Title string
+    GlobalChecksum uint16
+}
+
+type cpuState struct {
+    A, B, C, D, E, H, L uint8
+    PC, SP uint16
+    Zero, Subtract, Carry, HalfCarry bool
+    IME bool
+    Halted bool
+    HaltBug bool
+    InstructionsExecuted uint64
+}
+
+type mmuState struct {
+    IO []uint8 // 0xFF00-0xFFFF. The sound registers in this range are saved with the APU
+    VRAM []uint8
+    WRAM []uint8
+    OAM []uint8
+    STATMode uint8
+    STATLine bool
+    Buttons Buttons
+    DMAActive bool
+    DMASource uint16
+    DMABytesCopied int
+    DMACycles int
+    DMAPending bool
+}
+
+type timerState struct {
+    CyclesSinceLastTIMAUpdate int
+    CyclesSinceLastDIVUpdate int
+    CPUCycles uint64
+}
+
+// The memory goes first in each section, so that it sits at the same offset from one state
+// to the next however the other fields are encoded (see xorSections)
+type displayState struct {
+    Framebuffer []uint8 // So that a loaded (or rewound) state shows the right picture straight away
+    ScanlineCounter int
+    WindowLine uint8
+}
+
+// SaveState - Writes a snapshot of the whole machine. The ROM itself is not included
+func (gb *GameBoy) SaveState(writer io.Writer) error {
+    mapper, err := gb.cpu.mmu.cart.mbc.saveState()
+    if err != nil {
+        return err
+    }
+    sections := []struct {
+        id string
+        value interface{}
+    }{
+        {"INFO", gb.infoState()},
+        {"CPU ", gb.cpu.state()},
+        {"MMU ", gb.cpu.mmu.state()},
+        {"TIMR", gb.cpu.timer.state()},
+        {"DISP", gb.display.state()},
+        {"APU ", gb.cpu.mmu.apu.state()},
+    }
+
+    var buffer bytes.Buffer
+    buffer.Write(stateMagic)
+    binary.Write(&buffer, binary.LittleEndian, uint16(STATEVERSION))
+    for _, section := range sections {
+        data, err := encodeSection(section.value)
+        if err != nil {
+            return err
+        }
+        writeChunk(&buffer, section.id, data)
+    }
+    writeChunk(&buffer, "CART", mapper)
+
+    _, err = writer.Write(buffer.Bytes())
+    return err
+}
+
+// LoadState - Restores a snapshot written by SaveState for the same ROM. The state is
+// checked completely before anything is changed, so a bad state leaves the machine as it was
+func (gb *GameBoy) LoadState(reader io.Reader) error {
+    chunks, err := readChunks(reader)
+    if err != nil {
+        return err
+    }
+    for _, id := range []string{"INFO", "CPU ", "MMU ", "TIMR", "DISP", "CART"} {
+        if _, ok := chunks[id]; !ok {
+            return &ErrInvalidState{fmt.Sprintf("missing %q section", id)}
+        }
+    }
+
+    var info infoState
+    var cpu cpuState
+    var mmu mmuState
+    var timer timerState
+    var display displayState
+    var apu apuState
+    sections := []struct {
+        id string
+        value interface{}
+    }{
+        {"INFO", &info}, {"CPU ", &cpu}, {"MMU ", &mmu}, {"TIMR", &timer}, {"DISP", &display}, {"APU ", &apu},
+    }
+    for _, section := range sections {
+        data, ok := chunks[section.id]
+        if !ok {
+            continue // Optional sections keep their power on state
+        }
+        if err := decodeSection(data, section.value); err != nil {
+            return &ErrInvalidState{fmt.Sprintf("section %q: %s", section.id, err)}
+        }
+    }
+    if info != gb.infoState() {
+        return &ErrInvalidState{fmt.Sprintf("the state belongs to %q, not %q", info.Title, gb.infoState().Title)}
+    }
+    if len(mmu.IO) != 0x100 || len(mmu.VRAM) != 0x2000 || len(mmu.WRAM) != 0x2000 || len(mmu.OAM) != 0xA0 {
+        return &ErrInvalidState{"memory sizes do not match"}
+    }
+    if err := mmu.validate(); err != nil {
+        return &ErrInvalidState{fmt.Sprintf("section \"MMU \": %s", err)}
+    }
+    if _, ok := chunks["APU "]; ok {
+        if err := apu.validate(); err != nil {
+            return &ErrInvalidState{fmt.Sprintf("section \"APU \": %s", err)}
+        }
+    }
+    if err := gb.cpu.mmu.cart.mbc.loadState(chunks["CART"]); err != nil {
+        return &ErrInvalidState{fmt.Sprintf("section \"CART\": %s", err)}
+    }
+
+    gb.cpu.loadState(cpu)
+    gb.cpu.mmu.loadState(mmu)
+    gb.cpu.timer.loadState(timer)
+    gb.display.loadState(display)
+    if _, ok := chunks["APU "]; ok {
+        gb.cpu.mmu.apu.loadState(apu)
+    }
+    return nil
+}
+
+// writeChunk - Appends a section to the state
+func writeChunk(buffer *bytes.Buffer, id string, data []byte) {
+    buffer.WriteString(id)
+    binary.Write(buffer, binary.LittleEndian, uint32(len(data)))
+    buffer.Write(data)
+}
+
+// readChunks - Checks the header and splits the rest of the state into its sections
+func readChunks(reader io.Reader) (map[string][]byte, error) {
+    data, err := ioutil.ReadAll(reader)
+    if err != nil {
+        return nil, err
+    }
+    if len(data) < 6 || !bytes.Equal(data[:4], stateMagic) {
+        return nil, &ErrInvalidState{"not a save state"}
+    }
+    version := binary.LittleEndian.Uint16(data[4:])
+    if version > STATEVERSION {
+        return nil, &ErrInvalidState{fmt.Sprintf("version %d was written by a newer emulator (this one reads up to %d)", version, STATEVERSION)}
+    }
+
+    chunks := make(map[string][]byte)
+    data = data[6:]
+    for len(data) > 0 {
+        if len(data) < 8 {
+            return nil, &ErrInvalidState{"truncated section header"}
+        }
+        id := string(data[:4])
+        length := binary.LittleEndian.Uint32(data[4:])
+        if uint64(length) > uint64(len(data)-8) {
+            return nil, &ErrInvalidState{fmt.Sprintf("section %q is truncated", id)}
+        }
+        chunks[id] = data[8 : 8+length]
+        data = data[8+length:]
+    }
+    return chunks, nil
+}
+
+func encodeSection(value interface{}) ([]byte, error) {
+    var buffer bytes.Buffer
+    err := gob.NewEncoder(&buffer).Encode(value)
+    return buffer.Bytes(), err
+}
+
+func decodeSection(data []byte, value interface{}) error {
+    return gob.NewDecoder(bytes.NewReader(data)).Decode(value)
+}
+
+func (gb *GameBoy) infoState() infoState {
+    header := gb.cpu.mmu.cart.header
+    if header == nil {
+        return infoState{}
+    }
+    return infoState{header.Title, header.GlobalChecksum}
+}
+
+func (cpu *CPU) state() cpuState {
+    return cpuState{
+        cpu.ra, cpu.rb, cpu.rc, cpu.rd, cpu.re, cpu.rh, cpu.rl,
+        cpu.programCounter, cpu.stackPointer,
+        cpu.zero, cpu.subtract, cpu.carry, cpu.halfCarry,
+        cpu.inte, cpu.halted, cpu.haltBug, cpu.instructionsExecuted,
+    }
+}
+
+func (cpu *CPU) loadState(state cpuState) {
+    cpu.ra, cpu.rb, cpu.rc, cpu.rd, cpu.re, cpu.rh, cpu.rl = state.A, state.B, state.C, state.D, state.E, state.H, state.L
+    cpu.programCounter, cpu.stackPointer = state.PC, state.SP
+    cpu.zero, cpu.subtract, cpu.carry, cpu.halfCarry = state.Zero, state.Subtract, state.Carry, state.HalfCarry
+    cpu.inte = state.IME
+    cpu.halted = state.Halted
+    cpu.haltBug = state.HaltBug
+    cpu.instructionsExecuted = state.InstructionsExecuted
+    cpu.err = nil
+}
+
+func (mmu *MMU) state() mmuState {
+    return mmuState{
+        append([]uint8(nil), mmu.internalRAM[0xFF00:]...),
+        append([]uint8(nil), mmu.vram[:]...),
+        append([]uint8(nil), mmu.wram[:]...),
+        append([]uint8(nil), mmu.oam[:]...),
+        mmu.statMode, mmu.statLine, mmu.buttons,
+        mmu.dma.active, mmu.dma.source, mmu.dma.bytesCopied, mmu.dma.cycles, mmu.dma.pending,
+    }
+}
+
+// validate - Rejects values which the MMU would use as an index out of range
+func (state mmuState) validate() error {
+    if state.STATMode > 3 {
+        return fmt.Errorf("STAT mode %d", state.STATMode)
+    }
+    if state.DMABytesCopied < 0 || state.DMABytesCopied > DMALENGTH || (state.DMAActive && state.DMABytesCopied == DMALENGTH) {
+        return fmt.Errorf("%d bytes copied by DMA", state.DMABytesCopied)
+    }
+    if state.DMAActive && (state.DMACycles < 0 || state.DMACycles >= 4) {
+        return fmt.Errorf("%d DMA cycles", state.DMACycles)
+    }
+    if state.DMAPending && !state.DMAActive {
+        return fmt.Errorf("pending DMA which is not active")
+    }
+    return nil
+}
+
+func (mmu *MMU) loadState(state mmuState) {
+    copy(mmu.internalRAM[0xFF00:], state.IO)
+    copy(mmu.vram[:], state.VRAM)
+    copy(mmu.wram[:], state.WRAM)
+    copy(mmu.oam[:], state.OAM)
+    mmu.statMode = state.STATMode
+    mmu.statLine = state.STATLine
+    mmu.buttons = state.Buttons
+    mmu.dma = DMA{state.DMAActive, state.DMASource, state.DMABytesCopied, state.DMACycles, state.DMAPending}
+}
+
+func (timer *Timer) state() timerState {
+    return timerState{timer.cyclesSinceLastTIMAUpdate, timer.cyclesSinceLastDIVUpdate, timer.cpuCycles}
+}
+
+func (timer *Timer) loadState(state timerState) {
+    timer.cyclesSinceLastTIMAUpdate = state.CyclesSinceLastTIMAUpdate
+    timer.cyclesSinceLastDIVUpdate = state.CyclesSinceLastDIVUpdate
+    timer.cpuCycles = state.CPUCycles
+}
+
+func (display *Display) state() displayState {
+    return displayState{append([]uint8(nil), display.internalImage.Pix...), display.scanlineCounter, display.windowLine}
+}
+
+func (display *Display) loadState(state displayState) {
+    display.scanlineCounter = state.ScanlineCounter
+    display.windowLine = state.WindowLine
+    if len(state.Framebuffer) == len(display.internalImage.Pix) {
+        copy(display.internalImage.Pix, state.Framebuffer)
+    }
+}
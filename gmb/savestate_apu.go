@@ -0,0 +1,239 @@
+package gmb
+
+import (
+    "fmt"
+)
+
+// The "APU " section of a save state. The sample rate and any running recording belong
+// to the frontend, so they are not part of the state
+
+type lengthState struct {
+    Counter int
+    Enabled bool
+    Maximum int
+}
+
+type envelopeState struct {
+    Register uint8
+    Volume uint8
+    Timer int
+}
+
+type pulseState struct {
+    Enabled bool
+    Duty uint8
+    DutyStep int
+    Frequency int
+    Timer int
+    Length lengthState
+    Envelope envelopeState
+    SweepRegister uint8
+    SweepEnabled bool
+    SweepTimer int
+    ShadowFrequency int
+}
+
+type waveState struct {
+    Enabled bool
+    DACEnabled bool
+    VolumeCode uint8
+    Frequency int
+    Timer int
+    Position int
+    Sample uint8
+    Length lengthState
+}
+
+type noiseState struct {
+    Enabled bool
+    Polynomial uint8
+    LFSR uint16
+    Timer int
+    Length lengthState
+    Envelope envelopeState
+}
+
+type apuState struct {
+    Registers []uint8 // 0xFF10-0xFF3F, including wave RAM
+    Powered bool
+    FrameSequencerStep int
+    Pulse1, Pulse2 pulseState
+    Wave waveState
+    Noise noiseState
+}
+
+func (apu *APU) state() apuState {
+    return apuState{
+        append([]uint8(nil), apu.registers[:]...),
+        apu.powered,
+        apu.frameSequencerStep,
+        apu.pulse1.state(), apu.pulse2.state(),
+        apu.wave.state(),
+        apu.noise.state(),
+    }
+}
+
+// validate - Rejects values which the channels would use as an index out of range, or
+// which give a timer period of zero or less so that tick never catches up
+func (state apuState) validate() error {
+    if len(state.Registers) != 0x30 {
+        return fmt.Errorf("%d registers", len(state.Registers))
+    }
+    if state.FrameSequencerStep < 0 || state.FrameSequencerStep > 7 {
+        return fmt.Errorf("frame sequencer step %d", state.FrameSequencerStep)
+    }
+    for i, pulse := range []pulseState{state.Pulse1, state.Pulse2} {
+        if err := pulse.validate(); err != nil {
+            return fmt.Errorf("pulse %d: %s", i+1, err)
+        }
+    }
+    if err := state.Wave.validate(); err != nil {
+        return fmt.Errorf("wave: %s", err)
+    }
+    if err := state.Noise.validate(); err != nil {
+        return fmt.Errorf("noise: %s", err)
+    }
+    return nil
+}
+
+// validate - The maximum is only set on the first write to the channel
+func (length lengthState) validate(maximum int) error {
+    if (length.Maximum != 0 && length.Maximum != maximum) || length.Counter < 0 || length.Counter > maximum {
+        return fmt.Errorf("length %d of %d", length.Counter, length.Maximum)
+    }
+    return nil
+}
+
+func (envelope envelopeState) validate() error {
+    if envelope.Volume > 15 {
+        return fmt.Errorf("volume %d", envelope.Volume)
+    }
+    return nil
+}
+
+// validFrequency - The 11-bit frequency registers
+func validFrequency(frequency int) bool {
+    return frequency >= 0 && frequency <= 2047
+}
+
+func (pulse pulseState) validate() error {
+    if pulse.Duty > 3 || pulse.DutyStep < 0 || pulse.DutyStep > 7 {
+        return fmt.Errorf("duty %d step %d", pulse.Duty, pulse.DutyStep)
+    }
+    if !validFrequency(pulse.Frequency) || !validFrequency(pulse.ShadowFrequency) {
+        return fmt.Errorf("frequency %d (shadow %d)", pulse.Frequency, pulse.ShadowFrequency)
+    }
+    if pulse.Timer < 0 || pulse.Timer > 2048*4 {
+        return fmt.Errorf("timer %d", pulse.Timer)
+    }
+    if err := pulse.Length.validate(64); err != nil {
+        return err
+    }
+    return pulse.Envelope.validate()
+}
+
+func (wave waveState) validate() error {
+    if wave.VolumeCode > 3 || wave.Sample > 15 {
+        return fmt.Errorf("volume code %d sample %d", wave.VolumeCode, wave.Sample)
+    }
+    if !validFrequency(wave.Frequency) {
+        return fmt.Errorf("frequency %d", wave.Frequency)
+    }
+    if wave.Timer < 0 || wave.Timer > 2048*2 {
+        return fmt.Errorf("timer %d", wave.Timer)
+    }
+    if wave.Position < 0 || wave.Position > 31 {
+        return fmt.Errorf("position %d", wave.Position)
+    }
+    return wave.Length.validate(256)
+}
+
+func (noise noiseState) validate() error {
+    if noise.Timer < 0 || noise.Timer > noiseDivisors[7] << 15 {
+        return fmt.Errorf("timer %d", noise.Timer)
+    }
+    if err := noise.Length.validate(64); err != nil {
+        return err
+    }
+    return noise.Envelope.validate()
+}
+
+func (apu *APU) loadState(state apuState) {
+    copy(apu.registers[:], state.Registers)
+    apu.powered = state.Powered
+    apu.frameSequencerStep = state.FrameSequencerStep
+    apu.pulse1.loadState(state.Pulse1)
+    apu.pulse2.loadState(state.Pulse2)
+    apu.wave.loadState(state.Wave)
+    apu.noise.loadState(state.Noise)
+}
+
+func (length *LengthCounter) state() lengthState {
+    return lengthState{length.counter, length.enabled, length.maximum}
+}
+
+func (length *LengthCounter) loadState(state lengthState) {
+    length.counter, length.enabled, length.maximum = state.Counter, state.Enabled, state.Maximum
+}
+
+func (envelope *VolumeEnvelope) state() envelopeState {
+    return envelopeState{envelope.register, envelope.volume, envelope.timer}
+}
+
+func (envelope *VolumeEnvelope) loadState(state envelopeState) {
+    envelope.register, envelope.volume, envelope.timer = state.Register, state.Volume, state.Timer
+}
+
+func (pulse *PulseChannel) state() pulseState {
+    return pulseState{
+        pulse.enabled, pulse.duty, pulse.dutyStep, pulse.frequency, pulse.timer,
+        pulse.length.state(), pulse.envelope.state(),
+        pulse.sweepRegister, pulse.sweepEnabled, pulse.sweepTimer, pulse.shadowFrequency,
+    }
+}
+
+// loadState - hasSweep is part of the channel, not of the state
+func (pulse *PulseChannel) loadState(state pulseState) {
+    pulse.enabled = state.Enabled
+    pulse.duty = state.Duty
+    pulse.dutyStep = state.DutyStep
+    pulse.frequency = state.Frequency
+    pulse.timer = state.Timer
+    pulse.length.loadState(state.Length)
+    pulse.envelope.loadState(state.Envelope)
+    pulse.sweepRegister = state.SweepRegister
+    pulse.sweepEnabled = state.SweepEnabled
+    pulse.sweepTimer = state.SweepTimer
+    pulse.shadowFrequency = state.ShadowFrequency
+}
+
+func (wave *WaveChannel) state() waveState {
+    return waveState{
+        wave.enabled, wave.dacEnabled, wave.volumeCode, wave.frequency, wave.timer,
+        wave.position, wave.sample, wave.length.state(),
+    }
+}
+
+func (wave *WaveChannel) loadState(state waveState) {
+    wave.enabled = state.Enabled
+    wave.dacEnabled = state.DACEnabled
+    wave.volumeCode = state.VolumeCode
+    wave.frequency = state.Frequency
+    wave.timer = state.Timer
+    wave.position = state.Position
+    wave.sample = state.Sample
+    wave.length.loadState(state.Length)
+}
+
+func (noise *NoiseChannel) state() noiseState {
+    return noiseState{noise.enabled, noise.polynomial, noise.lfsr, noise.timer, noise.length.state(), noise.envelope.state()}
+}
+
+func (noise *NoiseChannel) loadState(state noiseState) {
+    noise.enabled = state.Enabled
+    noise.polynomial = state.Polynomial
+    noise.lfsr = state.LFSR
+    noise.timer = state.Timer
+    noise.length.loadState(state.Length)
+    noise.envelope.loadState(state.Envelope)
+}
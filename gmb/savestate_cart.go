@@ -0,0 +1,151 @@
+package gmb
+
+import (
+    "fmt"
+)
+
+// The "CART" section of a save state is written by the memory bank controller, since
+// every controller has different registers. Battery-backed RAM is included so that
+// loading a state also restores the in-game save it was taken with
+
+type romOnlyState struct {
+    RAM []uint8
+}
+
+type mbc1State struct {
+    RAM []uint8
+    RAMEnabled bool
+    ROMBank, UpperBits, BankingMode uint8
+}
+
+type mbc2State struct {
+    RAM []uint8
+    RAMEnabled bool
+    ROMBank uint8
+}
+
+type mbc3State struct {
+    RAM []uint8
+    RAMEnabled bool
+    ROMBank, RAMBank uint8
+    RTC []uint8 // The 48-byte .sav footer; empty without a clock
+    RTCLatchPrimed bool
+}
+
+type mbc5State struct {
+    RAM []uint8
+    RAMEnabled bool
+    ROMBank uint16
+    RAMBank uint8
+    RumbleOn bool
+}
+
+// checkRAMSize - A state can only be loaded into a cartridge with the same amount of RAM
+func checkRAMSize(saved []uint8, ram []uint8) error {
+    if len(saved) != len(ram) {
+        return fmt.Errorf("cartridge RAM is %d bytes, the state has %d", len(ram), len(saved))
+    }
+    return nil
+}
+
+func (mbc *ROMOnly) saveState() ([]byte, error) {
+    return encodeSection(romOnlyState{mbc.ram})
+}
+
+func (mbc *ROMOnly) loadState(data []byte) error {
+    var state romOnlyState
+    if err := decodeSection(data, &state); err != nil {
+        return err
+    }
+    if err := checkRAMSize(state.RAM, mbc.ram); err != nil {
+        return err
+    }
+    copy(mbc.ram, state.RAM)
+    return nil
+}
+
+func (mbc *MBC1) saveState() ([]byte, error) {
+    return encodeSection(mbc1State{mbc.ram, mbc.ramEnabled, mbc.romBank, mbc.upperBits, mbc.bankingMode})
+}
+
+func (mbc *MBC1) loadState(data []byte) error {
+    var state mbc1State
+    if err := decodeSection(data, &state); err != nil {
+        return err
+    }
+    if err := checkRAMSize(state.RAM, mbc.ram); err != nil {
+        return err
+    }
+    copy(mbc.ram, state.RAM)
+    mbc.ramEnabled = state.RAMEnabled
+    mbc.romBank = state.ROMBank
+    mbc.upperBits = state.UpperBits
+    mbc.bankingMode = state.BankingMode
+    return nil
+}
+
+func (mbc *MBC2) saveState() ([]byte, error) {
+    return encodeSection(mbc2State{mbc.ram[:], mbc.ramEnabled, mbc.romBank})
+}
+
+func (mbc *MBC2) loadState(data []byte) error {
+    var state mbc2State
+    if err := decodeSection(data, &state); err != nil {
+        return err
+    }
+    if err := checkRAMSize(state.RAM, mbc.ram[:]); err != nil {
+        return err
+    }
+    copy(mbc.ram[:], state.RAM)
+    mbc.ramEnabled = state.RAMEnabled
+    mbc.romBank = state.ROMBank
+    return nil
+}
+
+func (mbc *MBC3) saveState() ([]byte, error) {
+    state := mbc3State{RAM: mbc.ram, RAMEnabled: mbc.ramEnabled, ROMBank: mbc.romBank, RAMBank: mbc.ramBank}
+    if mbc.rtc != nil {
+        state.RTC = mbc.rtc.footer()
+        state.RTCLatchPrimed = mbc.rtc.latchPrimed
+    }
+    return encodeSection(state)
+}
+
+func (mbc *MBC3) loadState(data []byte) error {
+    var state mbc3State
+    if err := decodeSection(data, &state); err != nil {
+        return err
+    }
+    if err := checkRAMSize(state.RAM, mbc.ram); err != nil {
+        return err
+    }
+    copy(mbc.ram, state.RAM)
+    mbc.ramEnabled = state.RAMEnabled
+    mbc.romBank = state.ROMBank
+    mbc.ramBank = state.RAMBank
+    if mbc.rtc != nil && len(state.RTC) > 0 {
+        mbc.rtc.loadFooter(state.RTC)
+        mbc.rtc.latchPrimed = state.RTCLatchPrimed
+    }
+    return nil
+}
+
+func (mbc *MBC5) saveState() ([]byte, error) {
+    return encodeSection(mbc5State{mbc.ram, mbc.ramEnabled, mbc.romBank, mbc.ramBank, mbc.rumbleOn})
+}
+
+func (mbc *MBC5) loadState(data []byte) error {
+    var state mbc5State
+    if err := decodeSection(data, &state); err != nil {
+        return err
+    }
+    if err := checkRAMSize(state.RAM, mbc.ram); err != nil {
+        return err
+    }
+    copy(mbc.ram, state.RAM)
+    mbc.ramEnabled = state.RAMEnabled
+    mbc.romBank = state.ROMBank
+    mbc.ramBank = state.RAMBank
+    mbc.setRumble(state.RumbleOn) // Lets the frontend know if the motor changed
+    return nil
+}
@@ -0,0 +1,112 @@
+package gmb
+
+import (
+    "bytes"
+    "encoding/binary"
+    "testing"
+)
+
+func TestSaveStateRoundTrip(t *testing.T) {
+    gb, err := New(validROM(0x13, 4, 0x03), WithSaveFile(""), WithSampleRate(22050)) // MBC3+RAM+BATTERY
+    if err != nil {
+        t.Fatal(err)
+    }
+    mmu := gb.cpu.mmu
+    mmu.write8(0x0000, 0x0A)
+    mmu.write8(0x2000, 0x03)
+    mmu.write8(0xA123, 0x42)
+    mmu.write8(0xFF26, 0x80)
+    mmu.write8(0xFF12, 0xF0)
+    mmu.write8(0xFF14, 0x87)
+    for i := 0; i < 3; i++ {
+        gb.RunFrame()
+    }
+    gb.cpu.ra = 0x12
+    gb.cpu.carry = true
+
+    var saved bytes.Buffer
+    if err := gb.SaveState(&saved); err != nil {
+        t.Fatal(err)
+    }
+
+    // Change everything, then go back
+    gb.RunFrame()
+    gb.cpu.ra = 0x99
+    mmu.write8(0xA123, 0x00)
+    mmu.write8(0x2000, 0x01)
+    mmu.write8(0xC000, 0x77)
+    if err := gb.LoadState(bytes.NewReader(saved.Bytes())); err != nil {
+        t.Fatal(err)
+    }
+
+    var again bytes.Buffer
+    gb.SaveState(&again)
+    if !bytes.Equal(saved.Bytes(), again.Bytes()) {
+        t.Errorf("Saving after a load should produce the same state")
+    }
+    if gb.cpu.ra != 0x12 || !gb.cpu.carry || mmu.read8(0xA123) != 0x42 || mmu.read8(0x4000) != 3 || mmu.read8(0xC000) != 0 {
+        t.Errorf("State was not restored")
+    }
+}
+
+func TestLoadStateRejectsBadStates(t *testing.T) {
+    gb, _ := New(validROM(0x00, 2, 0x00))
+    var saved bytes.Buffer
+    gb.SaveState(&saved)
+    state := saved.Bytes()
+
+    other := validROM(0x00, 2, 0x00)
+    copy(other[0x134:], "OTHER")
+    header, _ := ParseHeader(other)
+    other[0x14D] = header.computedHeaderChecksum
+    otherGB, _ := New(other)
+
+    newer := append([]byte(nil), state...)
+    binary.LittleEndian.PutUint16(newer[4:], STATEVERSION+1)
+
+    // badState - A state saved from a machine with one field out of range
+    badState := func(modify func(gb *GameBoy)) []byte {
+        bad, _ := New(validROM(0x00, 2, 0x00))
+        modify(bad)
+        var saved bytes.Buffer
+        bad.SaveState(&saved)
+        return saved.Bytes()
+    }
+
+    for name, test := range map[string]struct {
+        gb *GameBoy
+        state []byte
+    }{
+        "garbage":   {gb, []byte("hello world")},
+        "truncated": {gb, state[:len(state)-10]},
+        "newer":     {gb, newer},
+        "other ROM": {otherGB, state},
+        "duty":      {gb, badState(func(gb *GameBoy) { gb.cpu.mmu.apu.pulse1.duty = 4 })},
+        "duty step": {gb, badState(func(gb *GameBoy) { gb.cpu.mmu.apu.pulse2.dutyStep = -1 })},
+        "pulse frequency": {gb, badState(func(gb *GameBoy) { gb.cpu.mmu.apu.pulse1.frequency = 2048 })},
+        "wave frequency":  {gb, badState(func(gb *GameBoy) { gb.cpu.mmu.apu.wave.frequency = 4000 })},
+        "wave position":   {gb, badState(func(gb *GameBoy) { gb.cpu.mmu.apu.wave.position = 32 })},
+        "noise timer":     {gb, badState(func(gb *GameBoy) { gb.cpu.mmu.apu.noise.timer = -1 })},
+        "length":          {gb, badState(func(gb *GameBoy) { gb.cpu.mmu.apu.noise.length.counter = 65 })},
+        "DMA":             {gb, badState(func(gb *GameBoy) { gb.cpu.mmu.dma = DMA{true, 0xC000, DMALENGTH, 0, false} })},
+        "DMA cycles":      {gb, badState(func(gb *GameBoy) { gb.cpu.mmu.dma = DMA{true, 0xC000, 0, -4, false} })},
+        "DMA pending":     {gb, badState(func(gb *GameBoy) { gb.cpu.mmu.dma = DMA{false, 0xC000, 0, 0, true} })},
+        "STAT mode":       {gb, badState(func(gb *GameBoy) { gb.cpu.mmu.statMode = 4 })},
+    } {
+        before := test.gb.cpu.mmu.apu.state()
+        err := test.gb.LoadState(bytes.NewReader(test.state))
+        if _, ok := err.(*ErrInvalidState); !ok {
+            t.Errorf("%s: expected ErrInvalidState, got %v", name, err)
+        }
+        if after := test.gb.cpu.mmu.apu.state(); after.Pulse1 != before.Pulse1 || after.Wave != before.Wave {
+            t.Errorf("%s: a rejected state should not change the machine", name)
+        }
+    }
+
+    // Sections that this version does not know about are skipped
+    withExtra := append([]byte(nil), state...)
+    withExtra = append(withExtra, 'N', 'E', 'W', ' ', 3, 0, 0, 0, 1, 2, 3)
+    if err := gb.LoadState(bytes.NewReader(withExtra)); err != nil {
+        t.Errorf("Unknown sections should be skipped: %s", err)
+    }
+}
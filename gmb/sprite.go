@@ -0,0 +1,81 @@
+package gmb
+
+import (
+    "sort"
+)
+
+// MAXSPRITESPERLINE - The PPU only picks up the first 10 sprites (in OAM order) on each scanline
+const MAXSPRITESPERLINE = 10
+
+// Sprite - One of the 40 four-byte entries in OAM
+//
+//   Byte 0  Y position + 16
+//   Byte 1  X position + 8
+//   Byte 2  Tile number (always addressed from 0x8000)
+//   Byte 3  Flags
+//             Bit 7  BG and window colors 1-3 are drawn over the sprite
+//             Bit 6  Y flip
+//             Bit 5  X flip
+//             Bit 4  Palette (0=OBP0, 1=OBP1)
+type Sprite struct {
+    y uint8
+    x uint8
+    tile uint8
+    flags uint8
+    index int // Position in OAM, used to break ties between sprites with the same X
+}
+
+func (sprite Sprite) behindBackground() bool {
+    return (sprite.flags >> 7) & 0x1 == 0x1
+}
+
+func (sprite Sprite) yFlip() bool {
+    return (sprite.flags >> 6) & 0x1 == 0x1
+}
+
+func (sprite Sprite) xFlip() bool {
+    return (sprite.flags >> 5) & 0x1 == 0x1
+}
+
+func (sprite Sprite) palette() uint8 {
+    return (sprite.flags >> 4) & 0x1
+}
+
+// spritesOnLine - Performs the OAM search for a scanline. Returns at most 10 sprites,
+// ordered by drawing priority: on the DMG the sprite with the smaller X wins and
+// ties go to the sprite that comes first in OAM
+func (mmu * MMU) spritesOnLine(ly uint8) []Sprite {
+    height := int(mmu.spriteHeight())
+    sprites := make([]Sprite, 0, MAXSPRITESPERLINE)
+    for i := 0; i < 40 && len(sprites) < MAXSPRITESPERLINE; i++ {
+        entry := mmu.oam[i*4 : i*4+4]
+        top := int(entry[0]) - 16
+        if int(ly) < top || int(ly) >= top+height {
+            continue
+        }
+        sprites = append(sprites, Sprite{entry[0], entry[1], entry[2], entry[3], i})
+    }
+
+    sort.SliceStable(sprites, func(a, b int) bool {
+        return sprites[a].x < sprites[b].x
+    })
+    return sprites
+}
+
+// spriteColorNumber - Returns the 2-bit color number of the sprite at the given row and
+// column, where both are relative to the top-left corner of the sprite
+func (mmu * MMU) spriteColorNumber(sprite Sprite, row uint8, col uint8) uint8 {
+    height := mmu.spriteHeight()
+    tile := sprite.tile
+    if height == 16 {
+        tile &= 0xFE // The lowest bit is ignored for 8x16 sprites
+    }
+    if sprite.yFlip() {
+        row = height - 1 - row
+    }
+    if sprite.xFlip() {
+        col = 7 - col
+    }
+    rowAddress := 0x8000 + uint16(tile)*16 + uint16(row)*2 // Row 8-15 runs into the next tile
+    return mmu.tileColorNumber(rowAddress, col)
+}
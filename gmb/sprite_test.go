@@ -0,0 +1,89 @@
+package gmb
+
+import "testing"
+
+// spriteTestDisplay - A display with the LCD, background and sprites enabled and a
+// tile 1 which has color 3 in its leftmost column and color 1 everywhere else
+func spriteTestDisplay() *Display {
+    cpu := testCPU()
+    mmu := cpu.mmu
+    mmu.write8(0xFF40, 0x93)
+    mmu.write8(0xFF48, 0xE4) // OBP0 = identity
+    mmu.write8(0xFF49, 0x1B) // OBP1 = inverted
+    for row := uint16(0); row < 8; row++ {
+        mmu.vram[0x10+row*2] = 0xFF
+        mmu.vram[0x10+row*2+1] = 0x80
+    }
+    return newDisplay(cpu)
+}
+
+func setSprite(mmu *MMU, index int, y uint8, x uint8, tile uint8, flags uint8) {
+    copy(mmu.oam[index*4:], []uint8{y, x, tile, flags})
+}
+
+func pixelAt(display *Display, x int, y int) int {
+    pixel := 4 * (y*int(LCDWIDTH) + x)
+    p := display.internalImage.Pix
+    return int(p[pixel])<<24 | int(p[pixel+1])<<16 | int(p[pixel+2])<<8 | int(p[pixel+3])
+}
+
+func TestSpriteFlipAndPalette(t *testing.T) {
+    display := spriteTestDisplay()
+    setSprite(display.cpu.mmu, 0, 16, 8, 1, 0x00)
+    setSprite(display.cpu.mmu, 1, 16, 20, 1, 0x30) // X flip, OBP1
+    display.renderLine(0)
+
+    if pixelAt(display, 0, 0) != GameBoyColorMap[3] || pixelAt(display, 1, 0) != GameBoyColorMap[1] {
+        t.Errorf("Unflipped sprite drawn incorrectly")
+    }
+    if pixelAt(display, 19, 0) != GameBoyColorMap[0] || pixelAt(display, 18, 0) != GameBoyColorMap[2] {
+        t.Errorf("Flipped sprite should use OBP1 and have its dark column on the right")
+    }
+}
+
+func TestSpritePriority(t *testing.T) {
+    display := spriteTestDisplay()
+    mmu := display.cpu.mmu
+    setSprite(mmu, 0, 16, 12, 1, 0x10) // OBP1, but further right
+    setSprite(mmu, 1, 16, 8, 1, 0x00)  // Lower X wins even though it is later in OAM
+    display.renderLine(0)
+    if pixelAt(display, 4, 0) != GameBoyColorMap[1] {
+        t.Errorf("The sprite with the lower X should be drawn on top")
+    }
+
+    setSprite(mmu, 1, 16, 8, 1, 0x80) // Behind a background which is color 0 - still visible
+    display.renderLine(0)
+    if pixelAt(display, 0, 0) != GameBoyColorMap[3] {
+        t.Errorf("A sprite behind background color 0 should be visible")
+    }
+}
+
+func TestSpritesPerLineLimit(t *testing.T) {
+    display := spriteTestDisplay()
+    mmu := display.cpu.mmu
+    for i := 0; i < 12; i++ {
+        setSprite(mmu, i, 16, uint8(8+i*8), 1, 0x00)
+    }
+    if len(mmu.spritesOnLine(0)) != MAXSPRITESPERLINE {
+        t.Errorf("Only 10 sprites should be selected per line")
+    }
+    display.renderLine(0)
+    if pixelAt(display, 72, 0) != GameBoyColorMap[3] || pixelAt(display, 80, 0) != GameBoyColorMap[0] {
+        t.Errorf("The 11th sprite should not be drawn")
+    }
+}
+
+func TestTallSprites(t *testing.T) {
+    display := spriteTestDisplay()
+    mmu := display.cpu.mmu
+    mmu.write8(0xFF40, 0x97)
+    setSprite(mmu, 0, 16, 8, 1, 0x40) // Tile 1 becomes tile 0 (blank) + tile 1, then flipped vertically
+    display.renderLine(0)
+    if pixelAt(display, 0, 0) != GameBoyColorMap[3] {
+        t.Errorf("The flipped bottom tile of an 8x16 sprite should be on the first row")
+    }
+    display.renderLine(8)
+    if pixelAt(display, 0, 8) != GameBoyColorMap[0] {
+        t.Errorf("The flipped top tile of an 8x16 sprite should be on the second half")
+    }
+}
@@ -0,0 +1,100 @@
+package gmb
+
+import (
+    "fmt"
+)
+
+// WatchKind - Which accesses a watchpoint looks at
+type WatchKind int
+
+// The kinds can be combined; WatchAccess is both
+const (
+    WatchRead WatchKind = 1 << iota
+    WatchWrite
+    WatchAccess = WatchRead | WatchWrite
+)
+
+// Watchpoint - Stops the debugger when memory in Start-End (inclusive) is accessed
+type Watchpoint struct {
+    ID int // Assigned by Debugger.AddWatchpoint
+    Start, End uint16
+    Kind WatchKind
+    HasValue bool // Only accesses of Value count
+    Value uint8
+    Changed bool // Only writes which change the byte in memory count
+}
+
+// WatchpointHit - An access which matched a watchpoint
+type WatchpointHit struct {
+    Watchpoint Watchpoint
+    PC uint16 // Address of the instruction which made the access
+    Address uint16
+    Write bool
+    Value uint8 // The value read or written
+    OldValue uint8 // The byte in memory before a write
+}
+
+// String - e.g. "C0A0-C0AF write ==05"
+func (watchpoint Watchpoint) String() string {
+    text := fmt.Sprintf("%04X", watchpoint.Start)
+    if watchpoint.End != watchpoint.Start {
+        text += fmt.Sprintf("-%04X", watchpoint.End)
+    }
+    text += " " + [...]string{"", "read", "write", "access"}[watchpoint.Kind & WatchAccess]
+    if watchpoint.HasValue {
+        text += fmt.Sprintf(" ==%02X", watchpoint.Value)
+    }
+    if watchpoint.Changed {
+        text += " changed"
+    }
+    return text
+}
+
+// String - e.g. "Watchpoint 1 (C0A3 write): PC=0150 wrote 05 to C0A3 (was 00)"
+func (hit WatchpointHit) String() string {
+    if hit.Write {
+        return fmt.Sprintf("Watchpoint %d (%s): PC=%04X wrote %02X to %04X (was %02X)",
+            hit.Watchpoint.ID, hit.Watchpoint, hit.PC, hit.Value, hit.Address, hit.OldValue)
+    }
+    return fmt.Sprintf("Watchpoint %d (%s): PC=%04X read %02X from %04X",
+        hit.Watchpoint.ID, hit.Watchpoint, hit.PC, hit.Value, hit.Address)
+}
+
+// matches - Whether the access triggers the watchpoint
+func (watchpoint *Watchpoint) matches(address uint16, write bool, value uint8, changed bool) bool {
+    if address < watchpoint.Start || address > watchpoint.End {
+        return false
+    }
+    if write && watchpoint.Kind & WatchWrite == 0 || !write && watchpoint.Kind & WatchRead == 0 {
+        return false
+    }
+    if watchpoint.HasValue && value != watchpoint.Value {
+        return false
+    }
+    return !watchpoint.Changed || write && changed
+}
+
+// watchedRead8 - read8 while watchpoints are installed
+func (mmu *MMU) watchedRead8(address uint16) uint8 {
+    value := mmu.readBus(address)
+    mmu.checkWatchpoints(address, false, value, value, false)
+    return value
+}
+
+// watchedWrite8 - write8 while watchpoints are installed. A write counts as a change when
+// the byte reads back differently afterwards (so writes to ROM or to DIV may not)
+func (mmu *MMU) watchedWrite8(address uint16, data uint8) {
+    oldValue := mmu.peek(address)
+    mmu.writeBus(address, data)
+    mmu.checkWatchpoints(address, true, data, oldValue, mmu.peek(address) != oldValue)
+}
+
+// checkWatchpoints - Records a hit for every matching watchpoint. The PC is filled in by the debugger
+func (mmu *MMU) checkWatchpoints(address uint16, write bool, value uint8, oldValue uint8, changed bool) {
+    for i := range mmu.watchpoints {
+        if mmu.watchpoints[i].matches(address, write, value, changed) {
+            mmu.watchpointHits = append(mmu.watchpointHits,
+                WatchpointHit{Watchpoint: mmu.watchpoints[i], Address: address, Write: write, Value: value, OldValue: oldValue})
+        }
+    }
+}
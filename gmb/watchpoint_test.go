@@ -0,0 +1,91 @@
+package gmb
+
+import (
+    "testing"
+)
+
+// watchpointMachine - A machine running:
+//   0100: LD A,$05 / 0102: LD ($C0A3),A / 0105: LD ($C0A3),A / 0108: LD A,($C0A3) / 010B: JR $010B
+func watchpointMachine(t *testing.T) *Debugger {
+    gb, err := New(watchpointMachineROM())
+    if err != nil {
+        t.Fatal(err)
+    }
+    return NewDebugger(gb)
+}
+
+func watchpointMachineROM() []uint8 {
+    rom := validROM(0x00, 2, 0x00)
+    copy(rom[0x100:], []uint8{0x3E, 0x05, 0xEA, 0xA3, 0xC0, 0xEA, 0xA3, 0xC0, 0xFA, 0xA3, 0xC0, 0x18, 0xFE})
+    return rom
+}
+
+// expectWatchpointHit - Continues and checks that a single hit at pc stopped the run
+func expectWatchpointHit(t *testing.T, debugger *Debugger, pc uint16, write bool) WatchpointHit {
+    t.Helper()
+    reason, err := debugger.Continue()
+    if err != nil {
+        t.Fatal(err)
+    }
+    hits := debugger.WatchpointHits()
+    if reason != StopWatchpoint || len(hits) != 1 {
+        t.Fatalf("Expected a watchpoint hit, stopped (%s) with %v", reason, hits)
+    }
+    if hits[0].PC != pc || hits[0].Write != write || hits[0].Address != 0xC0A3 {
+        t.Errorf("Unexpected hit %s", hits[0])
+    }
+    return hits[0]
+}
+
+func TestWatchpointKinds(t *testing.T) {
+    debugger := watchpointMachine(t)
+    debugger.AddWatchpoint(Watchpoint{Start: 0xC0A0, End: 0xC0AF, Kind: WatchWrite})
+    hit := expectWatchpointHit(t, debugger, 0x0102, true)
+    if hit.Value != 0x05 || hit.OldValue != 0x00 || debugger.Registers().PC != 0x0105 {
+        t.Errorf("Unexpected hit %s, stopped at %04X", hit, debugger.Registers().PC)
+    }
+    expectWatchpointHit(t, debugger, 0x0105, true)
+
+    debugger = watchpointMachine(t)
+    debugger.AddWatchpoint(Watchpoint{Start: 0xC0A3, End: 0xC0A3, Kind: WatchRead})
+    if hit := expectWatchpointHit(t, debugger, 0x0108, false); hit.Value != 0x05 {
+        t.Errorf("Unexpected hit %s", hit)
+    }
+}
+
+func TestWatchpointConditions(t *testing.T) {
+    debugger := watchpointMachine(t)
+    debugger.AddWatchpoint(Watchpoint{Start: 0xC0A3, End: 0xC0A3, Kind: WatchWrite, Changed: true})
+    expectWatchpointHit(t, debugger, 0x0102, true)
+    if reason, _ := debugger.RunTo(0x010B); reason != StopStep {
+        t.Errorf("Writing the same value again is not a change")
+    }
+
+    debugger = watchpointMachine(t)
+    id := debugger.AddWatchpoint(Watchpoint{Start: 0xC0A3, End: 0xC0A3, Kind: WatchAccess, HasValue: true, Value: 0x06})
+    if reason, _ := debugger.RunTo(0x010B); reason != StopStep {
+        t.Errorf("The value 06 is never read or written")
+    }
+    if !debugger.RemoveWatchpoint(id) || debugger.RemoveWatchpoint(id) {
+        t.Errorf("RemoveWatchpoint should only report installed watchpoints")
+    }
+    debugger.Step()
+    if debugger.gb.cpu.mmu.watchpoints != nil {
+        t.Errorf("Without watchpoints the MMU should be back on the unwatched path")
+    }
+}
+
+func TestPeekIgnoresWatchpoints(t *testing.T) {
+    debugger := watchpointMachine(t)
+    debugger.AddWatchpoint(Watchpoint{Start: 0x0000, End: 0xFFFF, Kind: WatchAccess})
+    if reason, _ := debugger.Step(); reason != StopWatchpoint {
+        t.Fatalf("The instruction fetch should trigger the watchpoint")
+    }
+    debugger.gb.cpu.mmu.watchpointHits = nil
+    debugger.Disassemble(0x0100, 5)
+    debugger.ReadMemory(0xFF00, 0x100)
+    debugger.WriteMemory(0xC000, []uint8{1})
+    if len(debugger.gb.cpu.mmu.watchpointHits) != 0 {
+        t.Errorf("The debugger's own accesses should not trigger watchpoints: %v", debugger.gb.cpu.mmu.watchpointHits)
+    }
+}
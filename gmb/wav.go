@@ -0,0 +1,80 @@
+package gmb
+
+import (
+    "bufio"
+    "encoding/binary"
+    "io"
+)
+
+// WAVWriter - Streams 16-bit stereo PCM samples to a WAV file. The sizes in the
+// header are only known at the end, so they are filled in by Close
+type WAVWriter struct {
+    writer io.WriteSeeker
+    buffer *bufio.Writer // Samples arrive one at a time
+    dataSize uint32
+    err error // The first error; later writes are dropped
+}
+
+// wavHeaderSize - RIFF header + fmt chunk + data chunk header
+const wavHeaderSize = 44
+
+// NewWAVWriter - Writes the WAV header for the sample rate and returns a writer for the samples
+func NewWAVWriter(writer io.WriteSeeker, sampleRate int) (*WAVWriter, error) {
+    wav := &WAVWriter{writer: writer, buffer: bufio.NewWriter(writer)}
+    header := []interface{}{
+        []byte("RIFF"), uint32(0), []byte("WAVE"),
+        []byte("fmt "), uint32(16),
+        uint16(1),                // PCM
+        uint16(2),                // Stereo
+        uint32(sampleRate),
+        uint32(sampleRate * 4),   // Bytes per second
+        uint16(4),                // Bytes per frame (left + right)
+        uint16(16),               // Bits per sample
+        []byte("data"), uint32(0),
+    }
+    for _, field := range header {
+        if err := binary.Write(wav.buffer, binary.LittleEndian, field); err != nil {
+            return nil, err
+        }
+    }
+    return wav, nil
+}
+
+// WriteSamples - Appends interleaved left/right samples
+func (wav *WAVWriter) WriteSamples(samples []int16) error {
+    if wav.err != nil {
+        return wav.err
+    }
+    wav.err = binary.Write(wav.buffer, binary.LittleEndian, samples)
+    if wav.err == nil {
+        wav.dataSize += uint32(len(samples) * 2)
+    }
+    return wav.err
+}
+
+// Close - Fills in the RIFF and data chunk sizes. Does not close the underlying writer
+func (wav *WAVWriter) Close() error {
+    if wav.err != nil {
+        return wav.err
+    }
+    if err := wav.buffer.Flush(); err != nil {
+        return err
+    }
+    sizes := []struct {
+        offset int64
+        size uint32
+    }{
+        {4, wavHeaderSize - 8 + wav.dataSize},
+        {40, wav.dataSize},
+    }
+    for _, field := range sizes {
+        if _, err := wav.writer.Seek(field.offset, io.SeekStart); err != nil {
+            return err
+        }
+        if err := binary.Write(wav.writer, binary.LittleEndian, field.size); err != nil {
+            return err
+        }
+    }
+    _, err := wav.writer.Seek(0, io.SeekEnd)
+    return err
+}
@@ -0,0 +1,104 @@
+package gmb
+
+import (
+    "encoding/binary"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+func TestWAVWriter(t *testing.T) {
+    dir, err := ioutil.TempDir("", "gmb-wav")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer os.RemoveAll(dir)
+
+    file, err := os.Create(filepath.Join(dir, "out.wav"))
+    if err != nil {
+        t.Fatal(err)
+    }
+    wav, err := NewWAVWriter(file, 22050)
+    if err != nil {
+        t.Fatal(err)
+    }
+    wav.WriteSamples([]int16{1, -1, 2, -2})
+    wav.WriteSamples([]int16{3, -3})
+    if err := wav.Close(); err != nil {
+        t.Fatal(err)
+    }
+    file.Close()
+
+    data, _ := ioutil.ReadFile(file.Name())
+    if len(data) != 44+12 || string(data[0:4]) != "RIFF" || string(data[8:16]) != "WAVEfmt " {
+        t.Fatalf("Bad WAV file of %d bytes", len(data))
+    }
+    if binary.LittleEndian.Uint32(data[4:]) != 36+12 || binary.LittleEndian.Uint32(data[40:]) != 12 {
+        t.Errorf("Chunk sizes were not filled in")
+    }
+    if binary.LittleEndian.Uint32(data[24:]) != 22050 || binary.LittleEndian.Uint16(data[22:]) != 2 {
+        t.Errorf("Wrong sample rate or channel count")
+    }
+    if int16(binary.LittleEndian.Uint16(data[54:])) != -3 {
+        t.Errorf("Samples were not written in order")
+    }
+}
+
+func TestRecordIsolatedChannel(t *testing.T) {
+    dir, err := ioutil.TempDir("", "gmb-wav")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer os.RemoveAll(dir)
+
+    cpu := testCPU()
+    apu := cpu.mmu.apu
+    record := func(name string, channels Channels) []byte {
+        file, _ := os.Create(filepath.Join(dir, name))
+        defer file.Close()
+        if err := apu.startRecording(file, channels); err != nil {
+            t.Fatal(err)
+        }
+        apu.update(CYCLESPERFRAME)
+        if err := apu.stopRecording(); err != nil {
+            t.Fatal(err)
+        }
+        data, _ := ioutil.ReadFile(file.Name())
+        return data
+    }
+
+    cpu.mmu.write8(0xFF26, 0x80)
+    cpu.mmu.write8(0xFF24, 0x77)
+    cpu.mmu.write8(0xFF25, 0xFF)
+    cpu.mmu.write8(0xFF12, 0xF0)
+    cpu.mmu.write8(0xFF14, 0x87) // Pulse 1 playing
+
+    pulse := record("pulse.wav", Pulse1)
+    noise := record("noise.wav", Noise)
+    if len(pulse) < 44+738*4 {
+        t.Fatalf("Recording is too short: %d bytes", len(pulse))
+    }
+    if len(apu.takeSamples()) != 0 {
+        t.Errorf("Recording without WithSampleRate should not buffer samples")
+    }
+
+    silent := func(data []byte) bool {
+        for _, b := range data[44:] {
+            if b != 0 {
+                return false
+            }
+        }
+        return true
+    }
+    if silent(pulse) || !silent(noise) {
+        t.Errorf("Only the selected channels should be recorded")
+    }
+
+    if channels, err := ParseChannels("pulse1, noise"); err != nil || channels != Pulse1|Noise {
+        t.Errorf("ParseChannels returned %v, %v", channels, err)
+    }
+    if _, err := ParseChannels("drums"); err == nil {
+        t.Errorf("Unknown channel names should be rejected")
+    }
+}